@@ -68,12 +68,55 @@ func newRunCommand(action string) *ffcli.Command {
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy address (optional)")
 	fs.StringVar(&cfg.Output, "output", "output", "output directory (optional)")
 	fs.StringVar(&cfg.LogDir, "log", "logs", "log path, if empty, only logs to stdout (optional)")
+	fs.IntVar(&cfg.AuditMaxResult, "audit-max-result", 4096, "max bytes of each command result saved in the audit log, bigger results are saved as a sha256 hash, 0 means no limit (optional)")
 	fs.IntVar(&cfg.Steps, "steps", 0, "number of steps to run, if unset, it will run until it exits (optional)")
+	fs.IntVar(&cfg.Repairs, "repairs", 2, "number of times the ai is asked to fix a response that can't be parsed (optional)")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 4, "max number of commands without side effects run concurrently in each step (optional)")
+	fs.IntVar(&cfg.ResultTokens, "result-tokens", 2000, "max number of tokens of the command results sent back in each step, 0 means no limit (optional)")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", time.Hour, "time the results of idempotent commands (google, web) are reused, 0 disables the cache (optional)")
+	fs.StringVar(&cfg.CacheFile, "cache-file", "", "file where the cached results are persisted between sessions (optional)")
+	fs.Var((*stringSlice)(&cfg.Mounts), "mount", "read-only directory mounted in the output directory, in the format `name=path` (optional, repeatable)")
+
+	// Agents
+	fs.StringVar(&cfg.AgentModel, "agent-model", "", "model of the sub-agents started in auto mode, if empty the main model is used (optional)")
+	fs.IntVar(&cfg.AgentMax, "agent-max", 5, "max number of sub-agents alive at the same time in auto mode, 0 means no limit (optional)")
+
+	// Cmd input
+	fs.StringVar(&cfg.CmdInput, "in", "", "file with a batch of json commands per line to run in cmd mode, - to read from stdin, if unset and no prompt is provided an interactive console is started (optional)")
 
 	// Bulk files
 	fs.StringVar(&cfg.BulkInput, "bulk-in", "", "bulk input file")
 	fs.StringVar(&cfg.BulkOutput, "bulk-out", "", "bulk output file")
 
+	// Policy
+	fs.StringVar(&cfg.Policy, "policy", "", "yaml file with the rules to allow or deny commands (optional)")
+
+	fs.BoolVar(&cfg.UserInput, "user-input", false, "let the ai ask questions and queue the lines typed in the terminal as notes to the ai in auto mode (optional)")
+	fs.DurationVar(&cfg.AskTimeout, "ask-timeout", 5*time.Minute, "time to wait for the user to answer a question of the ai, 0 means no limit (optional)")
+
+	// Approval
+	fs.BoolVar(&cfg.Approve, "approve", false, "ask for approval before running risky commands in auto mode (optional)")
+	fs.Var((*stringSlice)(&cfg.ApproveCommands), "approve-commands", "commands that require approval (optional, repeatable, default bash,run_code,delete,write,append,replace,patch,download)")
+	fs.Var((*stringSlice)(&cfg.ApproveWriteAllow), "approve-write-allow", "glob of files that can be written without approval, e.g. `docs/**` (optional, repeatable)")
+
+	// Dry-run
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "don't run commands that change state (optional)")
+	fs.BoolVar(&cfg.DryRunAsk, "dry-run-ask", false, "ask the operator for a fake result of each command not run by dry-run, only in auto mode (optional)")
+
+	// Bash
+	fs.StringVar(&cfg.Sandbox, "sandbox", "direct", "sandbox used to run bash commands (direct, bwrap, firejail, nsjail, unshare, wrapper)")
+	fs.StringVar(&cfg.SandboxPrefix, "sandbox-prefix", "", "command prefix of the sandbox, {dir} is replaced with the output directory (optional, required for wrapper)")
+	fs.DurationVar(&cfg.BashTimeout, "bash-timeout", 2*time.Minute, "timeout of bash commands, 0 means no timeout (optional)")
+	fs.IntVar(&cfg.BashMaxOutput, "bash-max-output", 16*1024, "max bytes kept of stdout and stderr of bash commands, 0 means no limit (optional)")
+	fs.Var((*stringSlice)(&cfg.BashEnv), "bash-env", "environment variable passed to bash commands, a trailing * matches a prefix (optional, repeatable, default PATH,HOME,USER,LANG,LC_*,TERM,TMPDIR,TZ,SHELL)")
+	fs.Var((*stringSlice)(&cfg.Runtimes), "runtime", "runtime of the run_code command in the format `language=command`, e.g. python=python3.11 (optional, repeatable, default python=python3,node=node,go=go run,sh=sh)")
+
+	// HTTP
+	fs.Var((*stringSlice)(&cfg.HTTPAllow), "http-allow", "domain that the http command can request, subdomains included, if unset any domain is allowed (optional, repeatable)")
+	fs.Var((*stringSlice)(&cfg.HTTPDeny), "http-deny", "domain that the http command can't request, subdomains included (optional, repeatable)")
+
+	fs.Int64Var(&cfg.DownloadMaxSize, "download-max-size", 100*1024*1024, "max size in bytes of the files downloaded, 0 means no limit (optional)")
+
 	// Google
 	fs.StringVar(&cfg.GoogleKey, "google-key", "", "google api key, see https://developers.google.com/custom-search/v1/introduction")
 	fs.StringVar(&cfg.GoogleCX, "google-cx", "", "google cx (search engine ID), see https://cse.google.com/cse/all")
@@ -82,6 +125,7 @@ func newRunCommand(action string) *ffcli.Command {
 	fs.DurationVar(&cfg.OpenaiWait, "openai-wait", 5*time.Second, "wait between openai requests (optional)")
 	fs.StringVar(&cfg.OpenaiKey, "openai-key", "", "openai key (optional)")
 	fs.IntVar(&cfg.OpenaiMaxTokens, "openai-max-tokens", 5000, "openai max tokens per request")
+	fs.BoolVar(&cfg.OpenaiTools, "openai-tools", false, "use openai tool calling to run commands in auto mode (optional)")
 
 	// Chatgpt
 	fs.DurationVar(&cfg.ChatgptWait, "chatgpt-wait", 5*time.Second, "wait between chatgpt requests (optional)")
@@ -193,3 +237,20 @@ func loadSession(fs *flag.FlagSet, file string) error {
 		ff.WithConfigFileParser(ffyaml.Parser),
 	}...)
 }
+
+// stringSlice is a flag that can be set multiple times or with comma
+// separated values.
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(v string) error {
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			*s = append(*s, e)
+		}
+	}
+	return nil
+}
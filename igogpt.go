@@ -1,8 +1,10 @@
 package igogpt
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,12 +13,19 @@ import (
 	"strings"
 	"time"
 
-	"github.com/igolaizola/igogpt/internal/command"
+	"github.com/igolaizola/igogpt/internal/approval"
+	"github.com/igolaizola/igogpt/internal/console"
+	"github.com/igolaizola/igogpt/internal/dryrun"
+	"github.com/igolaizola/igogpt/internal/plan"
 	"github.com/igolaizola/igogpt/internal/prompt"
+	"github.com/igolaizola/igogpt/pkg/agent"
 	"github.com/igolaizola/igogpt/pkg/bing"
 	"github.com/igolaizola/igogpt/pkg/chatgpt"
+	"github.com/igolaizola/igogpt/pkg/command"
+	"github.com/igolaizola/igogpt/pkg/memory"
 	"github.com/igolaizola/igogpt/pkg/memory/fixed"
 	"github.com/igolaizola/igogpt/pkg/openai"
+	"github.com/igolaizola/igogpt/pkg/shell"
 )
 
 type Config struct {
@@ -29,10 +38,74 @@ type Config struct {
 	LogDir string `yaml:"log-dir"`
 	Steps  int    `yaml:"steps"`
 
+	// Number of times the AI is asked to fix a response that can't be parsed
+	Repairs int `yaml:"repairs"`
+
+	// Maximum number of commands run concurrently in each step
+	Concurrency int `yaml:"concurrency"`
+	// Maximum number of tokens of the command results of each step
+	ResultTokens int `yaml:"result-tokens"`
+
+	// Maximum size in bytes of the results saved in the audit log, bigger
+	// results are saved as a hash
+	AuditMaxResult int `yaml:"audit-max-result"`
+
+	// Cache of the results of idempotent commands, a zero TTL disables it
+	CacheTTL  time.Duration `yaml:"cache-ttl"`
+	CacheFile string        `yaml:"cache-file"`
+
+	// Read-only directories mounted in the output directory
+	Mounts []string `yaml:"mount"`
+
+	// Extra commands registered in the command runner, they replace the
+	// built-in commands with the same name
+	Commands []command.Command `yaml:"-"`
+
+	// Policy file with the rules to allow or deny commands
+	Policy string `yaml:"policy"`
+
+	// Allow the operator to answer questions and send notes in auto mode
+	UserInput  bool          `yaml:"user-input"`
+	AskTimeout time.Duration `yaml:"ask-timeout"`
+
+	// Approval parameters
+	Approve           bool     `yaml:"approve"`
+	ApproveCommands   []string `yaml:"approve-commands"`
+	ApproveWriteAllow []string `yaml:"approve-write-allow"`
+
+	// Simulate the commands that change state, asking the operator for the
+	// fake results if DryRunAsk is set
+	DryRun    bool `yaml:"dry-run"`
+	DryRunAsk bool `yaml:"dry-run-ask"`
+
+	// Agent parameters
+	AgentModel string `yaml:"agent-model"`
+	AgentMax   int    `yaml:"agent-max"`
+
+	// File with the commands of cmd mode, "-" for stdin
+	CmdInput string `yaml:"in"`
+
 	// Bulk parameters
 	BulkInput  string `yaml:"bulk-input"`
 	BulkOutput string `yaml:"bulk-output"`
 
+	// Bash parameters
+	Sandbox       string        `yaml:"sandbox"`
+	SandboxPrefix string        `yaml:"sandbox-prefix"`
+	BashTimeout   time.Duration `yaml:"bash-timeout"`
+	BashMaxOutput int           `yaml:"bash-max-output"`
+	BashEnv       []string      `yaml:"bash-env"`
+
+	// Runtimes of the run_code command in the format "language=command"
+	Runtimes []string `yaml:"runtime"`
+
+	// HTTP parameters
+	HTTPAllow []string `yaml:"http-allow"`
+	HTTPDeny  []string `yaml:"http-deny"`
+
+	// Maximum size in bytes of the files downloaded
+	DownloadMaxSize int64 `yaml:"download-max-size"`
+
 	// Google parameters
 	GoogleKey string `yaml:"google-key"`
 	GoogleCX  string `yaml:"google-cx"`
@@ -41,6 +114,7 @@ type Config struct {
 	OpenaiWait      time.Duration `yaml:"openai-wait"`
 	OpenaiKey       string        `yaml:"openai-key"`
 	OpenaiMaxTokens int           `yaml:"openai-max-tokens"`
+	OpenaiTools     bool          `yaml:"openai-tools"`
 
 	// Chatgpt parameters
 	ChatgptWait   time.Duration `yaml:"chatgpt-wait"`
@@ -131,14 +205,6 @@ func Auto(ctx context.Context, cfg *Config) error {
 	if cfg.Goal == "" && cfg.Prompt == "" {
 		return fmt.Errorf("igogpt: goal or prompt is required")
 	}
-	prmpt := fmt.Sprintf(prompt.Auto, cfg.Goal)
-	if cfg.BingSession.Cookie == "" {
-		prmpt = fmt.Sprintf(prompt.AutoNoBing, cfg.Goal)
-	}
-	if cfg.Prompt != "" {
-		prmpt = cfg.Prompt
-	}
-
 	if cfg.Output == "" {
 		return fmt.Errorf("igogpt: output is required")
 	}
@@ -146,8 +212,16 @@ func Auto(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("igogpt: couldn't create output directory: %w", err)
 	}
 
+	// Sub-agents use the same backend as the main chat
+	agentModel := cfg.AgentModel
+	if agentModel == "" {
+		agentModel = cfg.Model
+	}
+	var agentChat agent.ChatFunc
+
 	// Create main chat
 	var chat io.ReadWriter
+	var toolClient *openai.Client
 	switch cfg.AI {
 	case "bing":
 		return fmt.Errorf("igogpt: bing is not supported in auto mode")
@@ -162,9 +236,30 @@ func Auto(ctx context.Context, cfg *Config) error {
 		if err != nil {
 			return fmt.Errorf("igogpt: couldn't create chatgpt chat: %w", err)
 		}
+		agentChat = func(ctx context.Context) (io.ReadWriter, func(), error) {
+			c, err := client.Chat(ctx, agentModel)
+			if err != nil {
+				return nil, nil, err
+			}
+			return c, func() { _ = c.Close() }, nil
+		}
 	case "openai":
 		// Create openai client
 		client := openai.New(cfg.OpenaiKey, cfg.OpenaiWait, cfg.OpenaiMaxTokens)
+		agentChat = func(ctx context.Context) (io.ReadWriter, func(), error) {
+			c := client.Chat(ctx, agentModel, "user", fixed.NewFixedMemory(0, cfg.OpenaiMaxTokens))
+			close := func() {
+				if closer, ok := c.(io.Closer); ok {
+					_ = closer.Close()
+				}
+			}
+			return c, close, nil
+		}
+		if cfg.OpenaiTools {
+			// Commands are called using tools instead of parsing the text
+			toolClient = client
+			break
+		}
 		chat = client.Chat(ctx, cfg.Model, "system", fixed.NewFixedMemory(1, cfg.OpenaiMaxTokens))
 	default:
 		return fmt.Errorf("igogpt: invalid ai: %s", cfg.AI)
@@ -180,7 +275,6 @@ func Auto(ctx context.Context, cfg *Config) error {
 
 	// Create bing chat
 	var bingChat io.ReadWriter
-	bingChat = &notAvailable{}
 	if cfg.BingSession.Cookie != "" {
 		bingClient, err := bing.New(cfg.BingWait, &cfg.BingSession, cfg.BingSessionFile, cfg.Proxy)
 		if err != nil {
@@ -196,15 +290,43 @@ func Auto(ctx context.Context, cfg *Config) error {
 		log.Println("no bing session provided, skipping bing")
 	}
 
+	// Agent manager
+	agents := agent.New(agentChat, cfg.LogDir, cfg.AgentMax)
+	defer agents.Close()
+
 	// Command runner
 	ctx, exit := context.WithCancel(ctx)
-	runner := command.New(&command.Config{
-		Exit:      exit,
-		Output:    cfg.Output,
-		Bing:      bingChat,
-		GoogleKey: cfg.GoogleKey,
-		GoogleCX:  cfg.GoogleCX,
-	})
+	con := console.New(os.Stdin, os.Stdout)
+	runner, err := newRunner(cfg, exit, bingChat, agents, con)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+
+	// Plan tracker to follow the thoughts of the AI
+	tracker := plan.New(os.Stdout, cfg.LogDir)
+
+	// Queue the notes typed by the operator
+	if cfg.UserInput {
+		con.Listen()
+		log.Println("type a message and press enter to send a note to the AI")
+	}
+
+	if toolClient != nil {
+		return autoTools(ctx, cfg, toolClient, runner, logger, tracker, con)
+	}
+
+	// Generate the prompt with the registered commands
+	prmpt := fmt.Sprintf(prompt.Auto, cfg.Goal, runner.Help())
+	if bingChat == nil {
+		prmpt = fmt.Sprintf(prompt.AutoNoBing, cfg.Goal, runner.Help())
+	}
+	if cfg.UserInput {
+		prmpt = prompt.WithUserInput(prmpt)
+	}
+	if cfg.Prompt != "" {
+		prmpt = cfg.Prompt
+	}
 
 	send := prmpt
 	log.Println("starting auto mode")
@@ -223,21 +345,36 @@ func Auto(ctx context.Context, cfg *Config) error {
 		}
 		steps++
 
-		// Write to chat
-		if _, err := chat.Write([]byte(send)); err != nil {
-			return fmt.Errorf("igogpt: couldn't write message to chatgpt: %w", err)
+		// Write to chat and read the response
+		if notes := userNotes(con); notes != "" {
+			send = notes + send
 		}
-
-		// Read from chat
-		buf := make([]byte, 1024*64)
-		n, err := chat.Read(buf)
+		recv, err := talk(chat, send)
 		if err != nil {
-			return fmt.Errorf("igogpt: couldn't read message from chatgpt: %w", err)
+			return err
+		}
+
+		// Parse commands, asking the AI to fix the response if needed
+		step, err := runner.ParseStep(recv)
+		for i := 0; err != nil && i < cfg.Repairs; i++ {
+			log.Printf("asking to repair the response (%d/%d)", i+1, cfg.Repairs)
+			recv, err = talk(chat, repairMessage(err, runner.Help()))
+			if err != nil {
+				return err
+			}
+			step, err = runner.ParseStep(recv)
 		}
-		recv := string(buf[:n])
 
-		// Run commands
-		result := runner.Run(ctx, recv)
+		// Show thoughts and run commands
+		var result []map[string]any
+		if err != nil {
+			result = []map[string]any{{"error": err.Error()}}
+		} else {
+			if err := tracker.Update(steps, step.Thoughts); err != nil {
+				log.Println(err)
+			}
+			result = runner.Execute(command.WithStep(ctx, steps), step.Commands)
+		}
 
 		// Marshal data
 		js, err := json.MarshalIndent(result, "", "  ")
@@ -249,6 +386,149 @@ func Auto(ctx context.Context, cfg *Config) error {
 	}
 }
 
+// autoTools runs auto mode using the native tool calling of openai.
+// The registered commands are exposed as tools and their results are sent
+// back as tool messages.
+func autoTools(ctx context.Context, cfg *Config, client *openai.Client, runner *command.Runner, logger *logger, tracker *plan.Tracker, con *console.Console) error {
+	var tools []openai.Tool
+	for _, cmd := range runner.Commands() {
+		if cmd.Description() == "" {
+			continue
+		}
+		tools = append(tools, openai.Tool{
+			Name:        cmd.Name(),
+			Description: cmd.Description(),
+			Parameters:  command.Schema(cmd),
+		})
+	}
+	chat := client.ToolChat(cfg.Model, tools, fixed.NewFixedMemory(1, cfg.OpenaiMaxTokens))
+
+	prmpt := fmt.Sprintf(prompt.AutoTools, cfg.Goal)
+	if cfg.UserInput {
+		prmpt = prompt.WithUserInput(prmpt)
+	}
+	if cfg.Prompt != "" {
+		prmpt = cfg.Prompt
+	}
+	send := []memory.Message{{Role: "system", Content: prmpt}}
+
+	log.Println("starting auto mode with tools")
+	steps := 0
+	for {
+		// Check context
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		// Check steps
+		if cfg.Steps > 0 && steps >= cfg.Steps {
+			return nil
+		}
+		steps++
+
+		// Send messages and read the response
+		if notes := userNotes(con); notes != "" {
+			send = append(send, memory.Message{Role: "user", Content: notes})
+		}
+		for _, m := range send {
+			logger.sent(m.Content)
+		}
+		recv, err := chat.Send(ctx, send...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("igogpt: couldn't send message to openai: %w", err)
+		}
+		var sb strings.Builder
+		sb.WriteString(recv.Content)
+		for _, c := range recv.ToolCalls {
+			fmt.Fprintf(&sb, "\n%s %s", c.Name, c.Arguments)
+		}
+		logger.received(strings.TrimSpace(sb.String()))
+		if err := tracker.Update(steps, plan.FromText(recv.Content)); err != nil {
+			log.Println(err)
+		}
+
+		if len(recv.ToolCalls) == 0 {
+			send = []memory.Message{{Role: "user", Content: prompt.NoTools}}
+			continue
+		}
+
+		// Run the tool calls, arguments are always sent by name
+		results := make([]map[string]any, len(recv.ToolCalls))
+		var reqs []command.CommandRequest
+		var index []int
+		for i, c := range recv.ToolCalls {
+			args := map[string]any{}
+			if strings.TrimSpace(c.Arguments) != "" {
+				if err := json.Unmarshal([]byte(c.Arguments), &args); err != nil {
+					results[i] = map[string]any{"error": fmt.Sprintf("invalid arguments: %v", err)}
+					continue
+				}
+			}
+			reqs = append(reqs, command.CommandRequest{Name: c.Name, Args: []any{args}})
+			index = append(index, i)
+		}
+		for i, result := range runner.ExecuteEach(command.WithStep(ctx, steps), reqs) {
+			results[index[i]] = result
+		}
+
+		send = nil
+		for i, c := range recv.ToolCalls {
+			var content string
+			js, err := json.MarshalIndent(results[i], "", "  ")
+			if err != nil {
+				content = err.Error()
+			} else {
+				content = string(js)
+			}
+			send = append(send, memory.Message{
+				Role:       "tool",
+				Content:    content,
+				ToolCallID: c.ID,
+			})
+		}
+	}
+}
+
+// talk writes a message to the chat and returns the response.
+func talk(chat io.ReadWriter, msg string) (string, error) {
+	if _, err := chat.Write([]byte(msg)); err != nil {
+		return "", fmt.Errorf("igogpt: couldn't write message to chatgpt: %w", err)
+	}
+	buf := make([]byte, 1024*64)
+	n, err := chat.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("igogpt: couldn't read message from chatgpt: %w", err)
+	}
+	return string(buf[:n]), nil
+}
+
+// userNotes returns the notes queued by the operator formatted to be sent to
+// the AI, or an empty string if there are no notes.
+func userNotes(con *console.Console) string {
+	notes := con.Notes()
+	if len(notes) == 0 {
+		return ""
+	}
+	log.Printf("sending %d notes from the user\n", len(notes))
+	return fmt.Sprintf(prompt.UserNotes, strings.Join(notes, "\n"))
+}
+
+// repairMessage returns the message sent to the AI to fix a response that
+// couldn't be parsed.
+func repairMessage(err error, help string) string {
+	fragment := "(no JSON found in the response)"
+	var perr *command.ParseError
+	if errors.As(err, &perr) && perr.Excerpt != "" {
+		fragment = perr.Excerpt
+	}
+	return fmt.Sprintf(prompt.Repair, err, fragment, help)
+}
+
 // Pair connects two chats
 func Pair(ctx context.Context, cfg *Config) error {
 	if cfg.Goal == "" && cfg.Prompt == "" {
@@ -511,27 +791,223 @@ func Bulk(ctx context.Context, cfg *Config) error {
 	return nil
 }
 
-// Cmd runs a command and returns the result
+// Cmd runs commands and prints their results.
+// Commands are read from the prompt, from the input file (stdin if it is "-")
+// with a batch of commands per line, or interactively if neither is provided.
 func Cmd(ctx context.Context, cfg *Config) error {
-	// Bing chat not being available in this mode
-	runner := command.New(&command.Config{
-		Exit:      func() {},
-		Output:    cfg.Output,
-		Bing:      &notAvailable{},
-		GoogleKey: cfg.GoogleKey,
-		GoogleCX:  cfg.GoogleCX,
-	})
+	// Bing chat is shared by all the commands if a session is provided
+	var bingChat io.ReadWriter = &notAvailable{}
+	if cfg.BingSession.Cookie != "" {
+		bingClient, err := bing.New(cfg.BingWait, &cfg.BingSession, cfg.BingSessionFile, cfg.Proxy)
+		if err != nil {
+			return fmt.Errorf("igogpt: couldn't create bing client: %w", err)
+		}
+		chat, err := bingClient.Chat(ctx)
+		if err != nil {
+			return fmt.Errorf("igogpt: couldn't create bing chat: %w", err)
+		}
+		defer chat.Close()
+		bingChat = chat
+	}
 
-	// TODO: custom parameter for json input
-	result := runner.Run(ctx, cfg.Prompt)
-	out, err := json.MarshalIndent(result, "", "  ")
+	ctx, exit := context.WithCancel(ctx)
+	defer exit()
+	runner, err := newRunner(cfg, exit, bingChat, nil, nil)
 	if err != nil {
 		return err
 	}
-	fmt.Println(string(out))
+	defer runner.Close()
+
+	switch {
+	case cfg.CmdInput != "":
+		in := io.Reader(os.Stdin)
+		if cfg.CmdInput != "-" {
+			f, err := os.Open(cfg.CmdInput)
+			if err != nil {
+				return fmt.Errorf("igogpt: couldn't open input: %w", err)
+			}
+			defer f.Close()
+			in = f
+		}
+		return cmdBatches(ctx, runner, in, os.Stdout)
+	case cfg.Prompt != "":
+		result := runner.Run(command.WithStep(ctx, 1), cfg.Prompt)
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	default:
+		return cmdREPL(ctx, runner, os.Stdin, os.Stdout)
+	}
+}
+
+// cmdBatches runs each line of the input as a batch of commands and writes
+// the results as a JSON line.
+// Empty lines and lines starting with # are ignored.
+func cmdBatches(ctx context.Context, runner *command.Runner, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(out)
+	batch := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		batch++
+		if err := enc.Encode(runner.Run(command.WithStep(ctx, batch), line)); err != nil {
+			return fmt.Errorf("igogpt: couldn't write result: %w", err)
+		}
+		// Check context, the exit command cancels it
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("igogpt: couldn't read input: %w", err)
+	}
 	return nil
 }
 
+// cmdREPL reads commands interactively and prints their results.
+// The runner is kept between lines, so commands share their state.
+func cmdREPL(ctx context.Context, runner *command.Runner, in io.Reader, out io.Writer) error {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	fmt.Fprintln(out, "type commands in JSON format, .help to list the commands and .exit to quit")
+	for batch := 1; ; {
+		fmt.Fprint(out, "> ")
+		var line string
+		select {
+		case <-ctx.Done():
+			return nil
+		case l, ok := <-lines:
+			if !ok {
+				fmt.Fprintln(out)
+				return nil
+			}
+			line = strings.TrimSpace(l)
+		}
+		switch line {
+		case "":
+			continue
+		case ".exit", ".quit":
+			return nil
+		case ".help":
+			fmt.Fprint(out, runner.Help())
+			continue
+		}
+		js, err := json.MarshalIndent(runner.Run(command.WithStep(ctx, batch), line), "", "  ")
+		batch++
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		fmt.Fprintln(out, string(js))
+	}
+}
+
+// newRunner creates a command runner from the configuration.
+// The agent manager is optional, if nil the agent commands aren't available.
+// The console is used to interact with the operator, if nil approvals are
+// disabled and asking for dry-run results fails.
+func newRunner(cfg *Config, exit func(), bingChat io.ReadWriter, agents *agent.Manager, con *console.Console) (*command.Runner, error) {
+	executor, err := shell.NewExecutor(cfg.Sandbox, cfg.SandboxPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("igogpt: couldn't create sandbox: %w", err)
+	}
+	var policy *command.Policy
+	if cfg.Policy != "" {
+		policy, err = command.LoadPolicy(cfg.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("igogpt: couldn't load policy: %w", err)
+		}
+	}
+	runtimes, err := command.ParseRuntimes(cfg.Runtimes)
+	if err != nil {
+		return nil, fmt.Errorf("igogpt: couldn't parse runtimes: %w", err)
+	}
+	var cache *command.Cache
+	if cfg.CacheTTL > 0 {
+		cache, err = command.NewCache(cfg.CacheTTL, cfg.CacheFile)
+		if err != nil {
+			return nil, fmt.Errorf("igogpt: couldn't create cache: %w", err)
+		}
+	}
+	var audit *command.Auditor
+	if cfg.LogDir != "" {
+		audit, err = command.NewAuditor(cfg.LogDir, cfg.AuditMaxResult)
+		if err != nil {
+			return nil, fmt.Errorf("igogpt: couldn't create audit log: %w", err)
+		}
+	}
+	var asker command.Asker
+	if cfg.UserInput && con != nil {
+		asker = con
+	}
+	var simulator command.Simulator
+	if cfg.DryRun {
+		var c *console.Console
+		if cfg.DryRunAsk {
+			if con == nil {
+				return nil, errors.New("igogpt: dry-run-ask is only available in auto mode")
+			}
+			c = con
+		}
+		simulator = dryrun.New(c)
+	}
+	var approver command.Approver
+	if cfg.Approve && con != nil {
+		approver = approval.New(con, cfg.ApproveCommands, cfg.ApproveWriteAllow)
+	}
+	runner := command.New(&command.Config{
+		Exit:            exit,
+		Output:          cfg.Output,
+		LogDir:          cfg.LogDir,
+		Concurrency:     cfg.Concurrency,
+		TokenBudget:     cfg.ResultTokens,
+		Policy:          policy,
+		Approver:        approver,
+		Simulator:       simulator,
+		Asker:           asker,
+		AskTimeout:      cfg.AskTimeout,
+		Cache:           cache,
+		Audit:           audit,
+		Mounts:          cfg.Mounts,
+		Executor:        executor,
+		BashTimeout:     cfg.BashTimeout,
+		BashMaxOutput:   cfg.BashMaxOutput,
+		BashEnv:         cfg.BashEnv,
+		Runtimes:        runtimes,
+		Proxy:           cfg.Proxy,
+		HTTPAllow:       cfg.HTTPAllow,
+		HTTPDeny:        cfg.HTTPDeny,
+		DownloadMaxSize: cfg.DownloadMaxSize,
+		Agents:          agents,
+		Bing:            bingChat,
+		GoogleKey:       cfg.GoogleKey,
+		GoogleCX:        cfg.GoogleCX,
+	})
+	for _, cmd := range cfg.Commands {
+		runner.Register(cmd)
+	}
+	return runner, nil
+}
+
 type notAvailable struct{}
 
 func (r *notAvailable) Read(p []byte) (n int, err error) {
@@ -544,7 +1020,7 @@ func (r *notAvailable) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-func newLogger(rw io.ReadWriter, dir string) (io.ReadWriteCloser, error) {
+func newLogger(rw io.ReadWriter, dir string) (*logger, error) {
 	var f *os.File
 	if dir != "" {
 		// Create directory if it doesn't exist
@@ -582,12 +1058,7 @@ func (l *logger) Read(b []byte) (int, error) {
 	if err != nil {
 		return n, err
 	}
-	log.Println(">>>>>>>>>>>>>>>>>>>>")
-	fmt.Println(string(b[:n]))
-	if l.file != nil {
-		fmt.Fprintf(l.file, "%s: >>>>>>>>>>>>>>>>>>>>\n", time.Now().Format("2006-01-02 15-04-05"))
-		fmt.Fprintln(l.file, string(b[:n]))
-	}
+	l.received(string(b[:n]))
 	return n, err
 }
 
@@ -596,13 +1067,28 @@ func (l *logger) Write(b []byte) (int, error) {
 	if err != nil {
 		return n, err
 	}
+	l.sent(string(b[:n]))
+	return n, err
+}
+
+// received logs a message received from the AI.
+func (l *logger) received(msg string) {
+	log.Println(">>>>>>>>>>>>>>>>>>>>")
+	fmt.Println(msg)
+	if l.file != nil {
+		fmt.Fprintf(l.file, "%s: >>>>>>>>>>>>>>>>>>>>\n", time.Now().Format("2006-01-02 15-04-05"))
+		fmt.Fprintln(l.file, msg)
+	}
+}
+
+// sent logs a message sent to the AI.
+func (l *logger) sent(msg string) {
 	log.Println("<<<<<<<<<<<<<<<<<<<")
-	fmt.Println(string(b[:n]))
+	fmt.Println(msg)
 	if l.file != nil {
 		fmt.Fprintf(l.file, "%s: <<<<<<<<<<<<<<<<<<<\n", time.Now().Format("2006-01-02 15-04-05"))
-		fmt.Fprintln(l.file, string(b[:n]))
+		fmt.Fprintln(l.file, msg)
 	}
-	return n, err
 }
 
 type exitChecker struct {
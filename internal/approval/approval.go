@@ -0,0 +1,160 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/igolaizola/igogpt/internal/console"
+	"github.com/igolaizola/igogpt/pkg/command"
+)
+
+// DefaultCommands are the commands that require approval by default.
+var DefaultCommands = []string{"bash", "run_code", "delete", "write", "append", "replace", "patch", "download"}
+
+// writeCommands are the commands that don't require approval if their file
+// matches the write globs.
+var writeCommands = map[string]bool{
+	"write":    true,
+	"append":   true,
+	"replace":  true,
+	"patch":    true,
+	"download": true,
+}
+
+// Approver asks the operator to approve commands before they are run.
+type Approver struct {
+	console    *console.Console
+	commands   map[string]bool
+	writeGlobs []string
+
+	lck       sync.Mutex
+	always    map[string]bool
+	decisions map[string]error
+}
+
+// New creates an approver for the given commands.
+// Write commands whose file matches any of the write globs are approved
+// automatically.
+func New(c *console.Console, commands, writeGlobs []string) *Approver {
+	if len(commands) == 0 {
+		commands = DefaultCommands
+	}
+	lookup := map[string]bool{}
+	for _, name := range commands {
+		lookup[name] = true
+	}
+	return &Approver{
+		console:    c,
+		commands:   lookup,
+		writeGlobs: writeGlobs,
+		always:     map[string]bool{},
+		decisions:  map[string]error{},
+	}
+}
+
+// Approve asks the operator to approve the command if required.
+// It returns the arguments to run the command with, which may have been
+// edited by the operator, or an error if the command was rejected.
+// Decisions are remembered for the rest of the session.
+func (a *Approver) Approve(ctx context.Context, cmd command.Command, args command.Args) (command.Args, error) {
+	name := cmd.Name()
+	if !a.commands[name] {
+		return args, nil
+	}
+	if writeCommands[name] && matchGlobs(a.writeGlobs, args.String("filename")) {
+		return args, nil
+	}
+
+	// Check previous decisions
+	a.lck.Lock()
+	defer a.lck.Unlock()
+	if a.always[name] {
+		return args, nil
+	}
+	js, err := json.Marshal(map[string]any{name: args})
+	if err != nil {
+		return nil, fmt.Errorf("approval: couldn't marshal command: %w", err)
+	}
+	key := string(js)
+	if err, ok := a.decisions[key]; ok {
+		return args, err
+	}
+
+	a.console.Println("\ncommand requires approval:")
+	a.console.Println(key)
+	for {
+		answer, err := a.console.Ask(ctx, fmt.Sprintf("[y]es, [n]o, [e]dit, [a]lways allow %s: ", name))
+		if err != nil {
+			return nil, fmt.Errorf("approval: couldn't read answer: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			a.decisions[key] = nil
+			return args, nil
+		case "a", "always":
+			a.always[name] = true
+			return args, nil
+		case "n", "no":
+			msg, err := a.console.Ask(ctx, "message to the AI (optional): ")
+			if err != nil {
+				return nil, fmt.Errorf("approval: couldn't read message: %w", err)
+			}
+			reject := fmt.Errorf("command rejected by the operator")
+			if msg = strings.TrimSpace(msg); msg != "" {
+				reject = fmt.Errorf("command rejected by the operator: %s", msg)
+			}
+			a.decisions[key] = reject
+			return nil, reject
+		case "e", "edit":
+			edited, err := a.edit(ctx, cmd, args)
+			if err != nil {
+				a.console.Println(err)
+				continue
+			}
+			return edited, nil
+		}
+	}
+}
+
+// edit asks the operator for new arguments in JSON format.
+func (a *Approver) edit(ctx context.Context, cmd command.Command, args command.Args) (command.Args, error) {
+	js, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("approval: couldn't marshal arguments: %w", err)
+	}
+	a.console.Println("current arguments:", string(js))
+	answer, err := a.console.Ask(ctx, "new arguments as a JSON object: ")
+	if err != nil {
+		return nil, fmt.Errorf("approval: couldn't read arguments: %w", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(answer), &raw); err != nil {
+		return nil, fmt.Errorf("approval: invalid JSON: %w", err)
+	}
+	return command.Validate(cmd, []any{raw})
+}
+
+// matchGlobs returns whether the path matches any of the globs.
+// Globs ending with "/**" match any path inside the directory.
+func matchGlobs(globs []string, path string) bool {
+	if path == "" {
+		return false
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, g := range globs {
+		if strings.HasSuffix(g, "/**") {
+			if strings.HasPrefix(path, strings.TrimSuffix(g, "**")) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(g, path); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package approval
+
+import (
+	"context"
+	"testing"
+
+	"github.com/igolaizola/igogpt/internal/console"
+	"github.com/igolaizola/igogpt/internal/console/consoletest"
+	"github.com/igolaizola/igogpt/pkg/command"
+)
+
+func TestApprove(t *testing.T) {
+	ctx := context.Background()
+	runner := command.New(&command.Config{Output: t.TempDir()})
+	cmds := map[string]command.Command{}
+	for _, c := range runner.Commands() {
+		cmds[c.Name()] = c
+	}
+
+	in, out := consoletest.NewScript(
+		"y",              // approve bash ls
+		"n", "use rm -i", // reject bash rm
+		"e", `{"command":"pwd"}`, // edit bash cd
+		"a", // always allow delete
+	)
+	a := New(console.New(in, out), nil, []string{"docs/**"})
+
+	check := func(name string, args command.Args, want command.Args, wantErr bool) {
+		t.Helper()
+		got, err := a.Approve(ctx, cmds[name], args)
+		if wantErr {
+			if err == nil {
+				t.Fatalf("%s %v: expected error", name, args)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("%s %v: %v", name, args, err)
+		}
+		if got.String("command") != want.String("command") || got.String("filename") != want.String("filename") {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+
+	ls := command.Args{"command": "ls"}
+	rm := command.Args{"command": "rm -rf /"}
+	check("bash", ls, ls, false)
+	check("bash", rm, nil, true)
+	check("bash", command.Args{"command": "cd"}, command.Args{"command": "pwd"}, false)
+	check("delete", command.Args{"filename": "a.txt"}, command.Args{"filename": "a.txt"}, false)
+
+	// No more input is available, the following checks must not ask
+	check("bash", ls, ls, false)
+	check("bash", rm, nil, true)
+	check("delete", command.Args{"filename": "b.txt"}, command.Args{"filename": "b.txt"}, false)
+	check("write", command.Args{"filename": "docs/a.md"}, command.Args{"filename": "docs/a.md"}, false)
+	check("read", command.Args{"filename": "secret.txt"}, command.Args{"filename": "secret.txt"}, false)
+	check("write", command.Args{"filename": "main.go"}, nil, true)
+}
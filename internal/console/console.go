@@ -0,0 +1,120 @@
+package console
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Console reads operator input from a terminal.
+type Console struct {
+	in   io.Reader
+	out  io.Writer
+	once sync.Once
+	lck  sync.Mutex
+
+	// State of the background reading, protected by state
+	state  sync.Mutex
+	answer chan string
+	eof    bool
+	listen bool
+	notes  []string
+}
+
+// New creates a console that reads lines from in and writes questions to out.
+func New(in io.Reader, out io.Writer) *Console {
+	return &Console{
+		in:  in,
+		out: out,
+	}
+}
+
+// Ask prints the question and waits for the next line written by the
+// operator.
+// Only lines read after the question is printed are answers, lines typed
+// before are queued as notes or discarded.
+// Only one question is asked at a time, concurrent calls wait for their turn.
+func (c *Console) Ask(ctx context.Context, question string) (string, error) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	answer := make(chan string, 1)
+	c.state.Lock()
+	if c.eof {
+		c.state.Unlock()
+		return "", io.EOF
+	}
+	c.answer = answer
+	c.state.Unlock()
+	defer func() {
+		c.state.Lock()
+		c.answer = nil
+		c.state.Unlock()
+	}()
+	c.once.Do(func() {
+		go c.read()
+	})
+	fmt.Fprint(c.out, question)
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case line, ok := <-answer:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	}
+}
+
+// Listen starts reading the input in the background.
+// Lines written while no question is being asked are queued as notes.
+func (c *Console) Listen() {
+	c.state.Lock()
+	c.listen = true
+	c.state.Unlock()
+	c.once.Do(func() {
+		go c.read()
+	})
+}
+
+// Notes returns the queued notes and empties the queue.
+func (c *Console) Notes() []string {
+	c.state.Lock()
+	defer c.state.Unlock()
+	notes := c.notes
+	c.notes = nil
+	return notes
+}
+
+// Println prints a message to the operator.
+func (c *Console) Println(a ...any) {
+	fmt.Fprintln(c.out, a...)
+}
+
+func (c *Console) read() {
+	scanner := bufio.NewScanner(c.in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		c.state.Lock()
+		switch {
+		case c.answer != nil:
+			// The channel is buffered and cleared after the first line, so
+			// the send never blocks and no line is kept for a later question
+			c.answer <- line
+			c.answer = nil
+		case c.listen && strings.TrimSpace(line) != "":
+			c.notes = append(c.notes, line)
+		}
+		c.state.Unlock()
+	}
+	c.state.Lock()
+	defer c.state.Unlock()
+	c.eof = true
+	if c.answer != nil {
+		close(c.answer)
+		c.answer = nil
+	}
+}
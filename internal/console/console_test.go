@@ -0,0 +1,119 @@
+package console
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListen(t *testing.T) {
+	rd, wr := io.Pipe()
+	defer wr.Close()
+	c := New(rd, io.Discard)
+	c.Listen()
+
+	write := func(s string) {
+		t.Helper()
+		if _, err := wr.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitNotes := func(n int) []string {
+		t.Helper()
+		var notes []string
+		for i := 0; i < 100 && len(notes) < n; i++ {
+			notes = append(notes, c.Notes()...)
+			time.Sleep(10 * time.Millisecond)
+		}
+		return notes
+	}
+
+	// Lines written while nothing is asked are queued
+	write("focus on docs\n\nand tests\n")
+	if got := waitNotes(2); !reflect.DeepEqual(got, []string{"focus on docs", "and tests"}) {
+		t.Fatalf("unexpected notes %q", got)
+	}
+
+	// Lines written while a question is asked are answers
+	answer := make(chan string)
+	go func() {
+		a, err := c.Ask(context.Background(), "question? ")
+		if err != nil {
+			t.Error(err)
+		}
+		answer <- a
+	}()
+	waitAsking(c)
+	write("yes\n")
+	if a := <-answer; a != "yes" {
+		t.Errorf("unexpected answer %q", a)
+	}
+	if notes := c.Notes(); len(notes) != 0 {
+		t.Errorf("unexpected notes %q", notes)
+	}
+}
+
+func TestAskStale(t *testing.T) {
+	rd, wr := io.Pipe()
+	defer wr.Close()
+	c := New(rd, io.Discard)
+
+	write := func(s string) {
+		t.Helper()
+		if _, err := wr.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ask := func(ctx context.Context) <-chan string {
+		answer := make(chan string, 1)
+		go func() {
+			a, err := c.Ask(ctx, "question? ")
+			if err != nil {
+				a = err.Error()
+			}
+			answer <- a
+		}()
+		waitAsking(c)
+		return answer
+	}
+
+	// A canceled question doesn't keep the next line
+	ctx, cancel := context.WithCancel(context.Background())
+	answer := ask(ctx)
+	cancel()
+	if a := <-answer; a != context.Canceled.Error() {
+		t.Fatalf("unexpected answer %q", a)
+	}
+
+	// Lines typed while nothing is asked are discarded
+	write("stale\n")
+	answer = ask(context.Background())
+	write("fresh\n")
+	if a := <-answer; a != "fresh" {
+		t.Errorf("unexpected answer %q", a)
+	}
+
+	// The end of the input is returned to the current and next questions
+	answer = ask(context.Background())
+	wr.Close()
+	if a := <-answer; a != io.EOF.Error() {
+		t.Errorf("unexpected answer %q", a)
+	}
+	if _, err := c.Ask(context.Background(), "question? "); err != io.EOF {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func waitAsking(c *Console) {
+	for {
+		c.state.Lock()
+		asking := c.answer != nil
+		c.state.Unlock()
+		if asking {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,44 @@
+// Package consoletest provides utilities to test code that asks questions
+// through a console.
+package consoletest
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Script answers each question printed by the console with the next line and
+// closes the input when there are no lines left.
+// Lines are only written after the question is printed, so they aren't
+// discarded as stale input.
+type Script struct {
+	lck   sync.Mutex
+	w     *io.PipeWriter
+	lines []string
+}
+
+// NewScript returns the input and output to create a console that answers
+// its questions with the given lines.
+func NewScript(lines ...string) (io.Reader, io.Writer) {
+	rd, wr := io.Pipe()
+	return rd, &Script{w: wr, lines: lines}
+}
+
+// Write answers the question written to the output.
+func (s *Script) Write(p []byte) (int, error) {
+	// Questions don't end with a new line
+	if bytes.HasSuffix(p, []byte("\n")) {
+		return len(p), nil
+	}
+	s.lck.Lock()
+	defer s.lck.Unlock()
+	if len(s.lines) == 0 {
+		s.w.Close()
+		return len(p), nil
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	go s.w.Write([]byte(line + "\n"))
+	return len(p), nil
+}
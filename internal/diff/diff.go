@@ -0,0 +1,190 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Hunk is a hunk of a unified diff.
+type Hunk struct {
+	Header string
+	// OldStart is the line where the hunk starts in the original text, zero
+	// if the header doesn't include line numbers
+	OldStart int
+	Lines    []string
+}
+
+// Stats summarizes the changes applied by a patch.
+type Stats struct {
+	Hunks   int `json:"hunks"`
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// Parse parses the hunks of a unified diff.
+// File headers are ignored and hunk line counts aren't validated, so that
+// slightly malformed diffs can still be applied.
+// Diffs of more than one file are rejected.
+func Parse(patch string) ([]Hunk, error) {
+	var hunks []Hunk
+	var cur *Hunk
+	files := 0
+	lines := strings.Split(strings.TrimRight(patch, "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			if files++; files > 1 {
+				return nil, fmt.Errorf("diff: patch changes more than one file, send a patch for each file")
+			}
+		}
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			h := Hunk{Header: line}
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				h.OldStart, _ = strconv.Atoi(m[1])
+			}
+			hunks = append(hunks, h)
+			cur = &hunks[len(hunks)-1]
+		case cur == nil:
+			// Skip file headers
+			continue
+		case strings.HasPrefix(line, `\`):
+			// No newline at end of file
+			continue
+		case line == "":
+			// Empty context lines usually lose their leading space
+			cur.Lines = append(cur.Lines, " ")
+		case line[0] == ' ', line[0] == '+', line[0] == '-':
+			if strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") {
+				if i+1 < len(lines) && (strings.HasPrefix(lines[i+1], "+++ ") || strings.HasPrefix(lines[i+1], "@@")) {
+					// Header of the next file
+					cur = nil
+					continue
+				}
+			}
+			cur.Lines = append(cur.Lines, line)
+		default:
+			return nil, fmt.Errorf("diff: invalid line %d in hunk %q: %q", i+1, cur.Header, line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("diff: no hunks found, hunks must start with @@ -line,count +line,count @@")
+	}
+	return hunks, nil
+}
+
+// Apply applies a unified diff to the given text.
+// Hunks are searched near their line numbers and then in the whole text, so
+// the diff still applies if line numbers are wrong.
+func Apply(text, patch string) (string, *Stats, error) {
+	hunks, err := Parse(patch)
+	if err != nil {
+		return "", nil, err
+	}
+	trailing := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	stats := &Stats{}
+	offset, from := 0, 0
+	for i, h := range hunks {
+		var before, after []string
+		for _, l := range h.Lines {
+			switch l[0] {
+			case ' ':
+				before = append(before, l[1:])
+				after = append(after, l[1:])
+			case '-':
+				before = append(before, l[1:])
+				stats.Removed++
+			case '+':
+				after = append(after, l[1:])
+				stats.Added++
+			}
+		}
+		hint := h.OldStart - 1 + offset
+		if len(before) == 0 && h.OldStart > 0 {
+			// Additions without context are inserted after the start line
+			hint = h.OldStart + offset
+		}
+		pos, err := find(lines, before, hint, from)
+		if err != nil {
+			return "", nil, fmt.Errorf("diff: hunk %d (%s): %w", i+1, h.Header, err)
+		}
+		rest := append([]string{}, lines[pos+len(before):]...)
+		lines = append(append(lines[:pos], after...), rest...)
+		offset += len(after) - len(before)
+		from = pos + len(after)
+		stats.Hunks++
+	}
+
+	result := strings.Join(lines, "\n")
+	if trailing || (text == "" && len(lines) > 0) {
+		result += "\n"
+	}
+	return result, stats, nil
+}
+
+// find returns the position of the lines to be replaced, starting the search
+// at the hint position and moving outwards.
+func find(lines, before []string, hint, from int) (int, error) {
+	if hint < from {
+		hint = from
+	}
+	if hint > len(lines) {
+		hint = len(lines)
+	}
+	if len(before) == 0 {
+		return hint, nil
+	}
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) },
+	} {
+		for d := 0; d <= len(lines); d++ {
+			for _, pos := range []int{hint - d, hint + d} {
+				if pos < from || pos+len(before) > len(lines) {
+					continue
+				}
+				if match(lines[pos:pos+len(before)], before, equal) {
+					return pos, nil
+				}
+			}
+		}
+	}
+
+	// Report the closest partial match to help fixing the diff
+	best, bestN := -1, 0
+	for pos := from; pos < len(lines); pos++ {
+		n := 0
+		for n < len(before) && pos+n < len(lines) && strings.TrimSpace(lines[pos+n]) == strings.TrimSpace(before[n]) {
+			n++
+		}
+		if n > bestN {
+			best, bestN = pos, n
+		}
+	}
+	if best < 0 {
+		return 0, fmt.Errorf("line %q not found", before[0])
+	}
+	got := "end of file"
+	if best+bestN < len(lines) {
+		got = strconv.Quote(lines[best+bestN])
+	}
+	return 0, fmt.Errorf("context mismatch at line %d: expected %q, found %s", best+bestN+1, before[bestN], got)
+}
+
+func match(a, b []string, equal func(a, b string) bool) bool {
+	for i := range a {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
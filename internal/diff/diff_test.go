@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	text := "one\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		name    string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "replace",
+			patch: `--- a/file.txt
++++ b/file.txt
+@@ -2,3 +2,3 @@
+ two
+-three
++THREE
+ four
+`,
+			want: "one\ntwo\nTHREE\nfour\nfive\n",
+		},
+		{
+			name: "wrong-line-numbers",
+			patch: `@@ -20,2 +20,3 @@
+ four
++four and a half
+ five
+`,
+			want: "one\ntwo\nthree\nfour\nfour and a half\nfive\n",
+		},
+		{
+			name: "no-line-numbers",
+			patch: `@@
+-one
++zero
++one
+`,
+			want: "zero\none\ntwo\nthree\nfour\nfive\n",
+		},
+		{
+			name: "multiple-hunks",
+			patch: `@@ -1,2 +1,1 @@
+-one
+ two
+@@ -4,2 +3,2 @@
+ four
+-five
++5
+`,
+			want: "two\nthree\nfour\n5\n",
+		},
+		{
+			name: "missing-context",
+			patch: `@@ -2,2 +2,2 @@
+ two
+-tree
++THREE
+`,
+			wantErr: true,
+		},
+		{
+			name: "multiple-files",
+			patch: `--- a/file.txt
++++ b/file.txt
+@@ -1,1 +1,1 @@
+-one
++1
+--- a/other.txt
++++ b/other.txt
+@@ -2,1 +2,1 @@
+-two
++2
+`,
+			wantErr: true,
+		},
+		{
+			name:    "no-hunks",
+			patch:   "just text",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := Apply(text, tt.patch)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/igolaizola/igogpt/internal/console"
+	"github.com/igolaizola/igogpt/pkg/command"
+)
+
+// NotExecuted is the result returned when the operator doesn't type one.
+const NotExecuted = "dry-run: command not executed"
+
+// Simulator answers the commands that change state with simulated results.
+type Simulator struct {
+	console *console.Console
+}
+
+// New creates a simulator that asks the operator for the simulated results.
+// If the console is nil the commands are answered with NotExecuted without
+// asking, so unattended runs don't block.
+func New(c *console.Console) *Simulator {
+	return &Simulator{
+		console: c,
+	}
+}
+
+// Simulate returns the simulated result of the command, or false if the
+// command must be run.
+// The operator can type a result, which is used as JSON if it can be parsed,
+// or leave it empty to use NotExecuted.
+func (s *Simulator) Simulate(ctx context.Context, cmd command.Command, args command.Args) (any, bool) {
+	name := cmd.Name()
+	if s.console == nil {
+		return NotExecuted, true
+	}
+	js, err := json.Marshal(map[string]any{name: args})
+	if err != nil {
+		return NotExecuted, true
+	}
+	s.console.Println("\ndry-run, command not executed:")
+	s.console.Println(string(js))
+	answer, err := s.console.Ask(ctx, "fake result (empty for default): ")
+	if err != nil {
+		return NotExecuted, true
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return NotExecuted, true
+	}
+	var result any
+	if err := json.Unmarshal([]byte(answer), &result); err == nil {
+		return result, true
+	}
+	return fmt.Sprintf("dry-run: %s", answer), true
+}
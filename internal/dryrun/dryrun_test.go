@@ -0,0 +1,95 @@
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/igolaizola/igogpt/internal/console"
+	"github.com/igolaizola/igogpt/internal/console/consoletest"
+	"github.com/igolaizola/igogpt/pkg/command"
+)
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in, out := consoletest.NewScript(
+		"",                // default result for bash
+		`{"exit_code":0}`, // json result for bash
+		"file written",    // text result for write
+	)
+	runner := command.New(&command.Config{
+		Output:    dir,
+		Simulator: New(console.New(in, out)),
+	})
+	run := func(name string, args map[string]any) any {
+		t.Helper()
+		return runner.Execute(ctx, []command.CommandRequest{{Name: name, Args: []any{args}}})[0][name]
+	}
+
+	if got := run("bash", map[string]any{"command": "touch b.txt"}); got != NotExecuted {
+		t.Errorf("got %v, want %v", got, NotExecuted)
+	}
+	if got, want := run("bash", map[string]any{"command": "touch b.txt"}), map[string]any{"exit_code": float64(0)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := run("write", map[string]any{"filename": "b.txt", "contents": "x"}), "dry-run: file written"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("simulated commands were run: %v", err)
+	}
+
+	// Read-only commands are run
+	if got := run("read", map[string]any{"filename": "a.txt"}); !strings.Contains(fmt.Sprint(got), "hello") {
+		t.Errorf("read not run: %v", got)
+	}
+}
+
+func TestSimulateUnattended(t *testing.T) {
+	ctx := context.Background()
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+	}))
+	defer srv.Close()
+	exited := false
+	runner := command.New(&command.Config{
+		Output:    t.TempDir(),
+		Exit:      func() { exited = true },
+		Simulator: New(nil),
+	})
+	run := func(name string, args map[string]any) any {
+		t.Helper()
+		return runner.Execute(ctx, []command.CommandRequest{{Name: name, Args: []any{args}}})[0][name]
+	}
+
+	// Commands that change state get the default result without asking
+	if got := run("bash", map[string]any{"command": "touch b.txt"}); got != NotExecuted {
+		t.Errorf("got %v, want %v", got, NotExecuted)
+	}
+	if got := run("http", map[string]any{"url": srv.URL, "method": "POST"}); got != NotExecuted {
+		t.Errorf("got %v, want %v", got, NotExecuted)
+	}
+
+	// Commands with side effects that don't change state are run
+	if got := run("http", map[string]any{"url": srv.URL}); got == NotExecuted {
+		t.Errorf("http get not run")
+	}
+	if !reflect.DeepEqual(methods, []string{"GET"}) {
+		t.Errorf("unexpected requests %v", methods)
+	}
+	run("exit", map[string]any{})
+	if !exited {
+		t.Errorf("exit not run")
+	}
+}
@@ -0,0 +1,173 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/igogpt/pkg/command"
+)
+
+// Tracker prints a summary of the thoughts of each step and keeps track of
+// the changes of the plan.
+type Tracker struct {
+	out  io.Writer
+	file string
+	plan string
+}
+
+// New creates a tracker that prints the summaries to out.
+// The latest plan is saved to a file in the given directory, if the directory
+// is empty the plan isn't saved.
+func New(out io.Writer, dir string) *Tracker {
+	var file string
+	if dir != "" {
+		file = filepath.Join(dir, fmt.Sprintf("plan_%s.md", time.Now().Format("20060102_150405")))
+	}
+	return &Tracker{
+		out:  out,
+		file: file,
+	}
+}
+
+// Plan returns the latest plan.
+func (t *Tracker) Plan() string {
+	return t.plan
+}
+
+// Update prints the summary of the step and saves the plan if it changed.
+func (t *Tracker) Update(step int, thoughts command.Thoughts) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "step %d\n", step)
+	fields := []struct {
+		name string
+		text string
+	}{
+		{"thoughts", thoughts.Text},
+		{"reasoning", thoughts.Reasoning},
+		{"criticism", thoughts.Criticism},
+		{"speak", thoughts.Speak},
+	}
+	for _, f := range fields {
+		if f.text == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "  %s: %s\n", f.name, indent(f.text, "    "))
+	}
+
+	// An empty plan means the AI didn't send it, so the previous one is kept
+	changed := thoughts.Plan != "" && thoughts.Plan != t.plan
+	switch {
+	case !changed && t.plan != "":
+		fmt.Fprintln(&sb, "  plan: unchanged")
+	case changed && t.plan == "":
+		fmt.Fprintf(&sb, "  plan:\n%s\n", indent("    "+thoughts.Plan, "    "))
+	case changed:
+		fmt.Fprintln(&sb, "  plan changed:")
+		for _, l := range Diff(t.plan, thoughts.Plan) {
+			fmt.Fprintf(&sb, "    %s\n", l)
+		}
+	}
+	fmt.Fprint(t.out, sb.String())
+
+	if !changed {
+		return nil
+	}
+	t.plan = thoughts.Plan
+	if t.file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(t.file), 0700); err != nil {
+		return fmt.Errorf("plan: couldn't create directory: %w", err)
+	}
+	data := fmt.Sprintf("# Plan (step %d)\n\n%s\n", step, t.plan)
+	if err := os.WriteFile(t.file, []byte(data), 0600); err != nil {
+		return fmt.Errorf("plan: couldn't write plan: %w", err)
+	}
+	return nil
+}
+
+// Diff returns the lines of the new plan prefixed with "+" if they were
+// added, "-" if they were removed or " " if they didn't change.
+func Diff(before, after string) []string {
+	a := lines(before)
+	b := lines(after)
+
+	// Longest common subsequence of lines
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff = append(diff, "  "+b[j])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
+
+var listItem = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+
+// FromText returns the thoughts of a plain text response, used when the AI
+// doesn't send them as JSON.
+// The items of the bulleted or numbered lists are the plan and the rest of
+// the lines the text.
+func FromText(text string) command.Thoughts {
+	var rest, plan []string
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if loc := listItem.FindStringIndex(l); loc != nil {
+			plan = append(plan, "- "+strings.TrimSpace(l[loc[1]:]))
+			continue
+		}
+		rest = append(rest, strings.TrimSpace(l))
+	}
+	return command.Thoughts{
+		Text: strings.Join(rest, "\n"),
+		Plan: strings.Join(plan, "\n"),
+	}
+}
+
+func lines(text string) []string {
+	var ls []string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+func indent(text, prefix string) string {
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n"+prefix)
+}
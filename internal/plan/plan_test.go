@@ -0,0 +1,71 @@
+package plan
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/igolaizola/igogpt/pkg/command"
+)
+
+func TestDiff(t *testing.T) {
+	before := "- search docs\n- write code\n- test"
+	after := "- search docs\n- write tests\n- write code\n- deploy"
+	want := []string{
+		"  - search docs",
+		"+ - write tests",
+		"  - write code",
+		"- - test",
+		"+ - deploy",
+	}
+	if got := Diff(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	tr := New(&out, dir)
+
+	if err := tr.Update(1, command.Thoughts{Text: "start", Plan: "- a\n- b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Update(2, command.Thoughts{Text: "continue"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Update(3, command.Thoughts{Plan: "- a\n- c"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"step 1", "thoughts: start", "- a", "plan: unchanged", "plan changed:", "- - b", "+ - c"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("output doesn't contain %q:\n%s", s, out.String())
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "plan_*.md"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one plan file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Plan (step 3)\n\n- a\n- c\n"; string(data) != want {
+		t.Errorf("plan file = %q, want %q", data, want)
+	}
+}
+
+func TestFromText(t *testing.T) {
+	text := "I need to know the files first.\n\nPlan:\n1. list the files\n2) read main.go\n  * fix the bug\n"
+	want := command.Thoughts{
+		Text: "I need to know the files first.\nPlan:",
+		Plan: "- list the files\n- read main.go\n- fix the bug",
+	}
+	if got := FromText(text); got != want {
+		t.Errorf("FromText() = %+v, want %+v", got, want)
+	}
+}
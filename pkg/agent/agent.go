@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChatFunc creates a new chat and returns it with a function to close it.
+type ChatFunc func(ctx context.Context) (io.ReadWriter, func(), error)
+
+// Manager keeps the sub-agents created by the AI.
+// Each agent is a child chat created with the same backend as the main chat.
+type Manager struct {
+	newChat ChatFunc
+	logDir  string
+	max     int
+
+	lck    sync.Mutex
+	agents map[string]*Agent
+}
+
+// Agent is a child chat with a name and a task.
+type Agent struct {
+	key      string
+	name     string
+	task     string
+	messages atomic.Int32
+
+	lck   sync.Mutex
+	chat  io.ReadWriter
+	close func()
+	log   *os.File
+}
+
+// Info describes an agent.
+type Info struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Task     string `json:"task"`
+	Messages int    `json:"messages"`
+}
+
+// New creates an agent manager that creates chats with the given function.
+// The transcript of each agent is saved to the log directory, if it is empty
+// the transcripts aren't saved.
+// Max is the maximum number of agents alive at the same time, zero means no
+// limit.
+func New(newChat ChatFunc, logDir string, max int) *Manager {
+	return &Manager{
+		newChat: newChat,
+		logDir:  logDir,
+		max:     max,
+		agents:  map[string]*Agent{},
+	}
+}
+
+var invalidKey = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// Start creates a new agent, sends it the prompt and returns the key of the
+// agent and its response.
+func (m *Manager) Start(ctx context.Context, name, task, prompt string) (string, string, error) {
+	m.lck.Lock()
+	if m.max > 0 && len(m.agents) >= m.max {
+		m.lck.Unlock()
+		return "", "", fmt.Errorf("agent: maximum number of agents (%d) reached, delete one first", m.max)
+	}
+	// Generate a unique key from the name
+	base := strings.Trim(invalidKey.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if base == "" {
+		base = "agent"
+	}
+	key := base
+	for i := 2; m.agents[key] != nil; i++ {
+		key = fmt.Sprintf("%s_%d", base, i)
+	}
+	a := &Agent{key: key, name: name, task: task}
+	// Reserve the key while the chat is created
+	m.agents[key] = a
+	m.lck.Unlock()
+
+	resp, err := m.start(ctx, a, prompt)
+	if err != nil {
+		m.lck.Lock()
+		delete(m.agents, key)
+		m.lck.Unlock()
+		a.Close()
+		return "", "", err
+	}
+	return key, resp, nil
+}
+
+func (m *Manager) start(ctx context.Context, a *Agent, prompt string) (string, error) {
+	a.lck.Lock()
+	defer a.lck.Unlock()
+	chat, close, err := m.newChat(ctx)
+	if err != nil {
+		return "", fmt.Errorf("agent: couldn't create chat: %w", err)
+	}
+	a.chat, a.close = chat, close
+	if m.logDir != "" {
+		if err := os.MkdirAll(m.logDir, 0700); err != nil {
+			return "", fmt.Errorf("agent: couldn't create log directory: %w", err)
+		}
+		filename := fmt.Sprintf("agent_%s_%s.txt", a.key, time.Now().Format("20060102_150405"))
+		a.log, err = os.OpenFile(filepath.Join(m.logDir, filename), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return "", fmt.Errorf("agent: couldn't create log file: %w", err)
+		}
+		fmt.Fprintf(a.log, "agent %s (%s): %s\n", a.key, a.name, a.task)
+	}
+	log.Printf("agent: %s started\n", a.key)
+	return a.talk(prompt)
+}
+
+// Message sends a message to the agent and returns its response.
+func (m *Manager) Message(ctx context.Context, key, message string) (string, error) {
+	a, err := m.get(key)
+	if err != nil {
+		return "", err
+	}
+	a.lck.Lock()
+	defer a.lck.Unlock()
+	if a.chat == nil {
+		return "", fmt.Errorf("agent: %q is not available", key)
+	}
+	return a.talk(message)
+}
+
+// List returns the information of the agents sorted by key.
+func (m *Manager) List() []Info {
+	m.lck.Lock()
+	defer m.lck.Unlock()
+	agents := []Info{}
+	for _, a := range m.agents {
+		agents = append(agents, Info{
+			Key:      a.key,
+			Name:     a.name,
+			Task:     a.task,
+			Messages: int(a.messages.Load()),
+		})
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Key < agents[j].Key
+	})
+	return agents
+}
+
+// Delete closes the agent and removes it from the manager.
+func (m *Manager) Delete(key string) error {
+	a, err := m.get(key)
+	if err != nil {
+		return err
+	}
+	m.lck.Lock()
+	delete(m.agents, key)
+	m.lck.Unlock()
+	a.Close()
+	log.Printf("agent: %s deleted\n", key)
+	return nil
+}
+
+// Close closes all the agents.
+func (m *Manager) Close() {
+	m.lck.Lock()
+	agents := m.agents
+	m.agents = map[string]*Agent{}
+	m.lck.Unlock()
+	for _, a := range agents {
+		a.Close()
+	}
+}
+
+func (m *Manager) get(key string) (*Agent, error) {
+	m.lck.Lock()
+	defer m.lck.Unlock()
+	a, ok := m.agents[key]
+	if !ok {
+		return nil, fmt.Errorf("agent: unknown agent %q", key)
+	}
+	return a, nil
+}
+
+// Close closes the chat and the transcript of the agent.
+func (a *Agent) Close() {
+	a.lck.Lock()
+	defer a.lck.Unlock()
+	if a.close != nil {
+		a.close()
+	}
+	a.chat, a.close = nil, nil
+	if a.log != nil {
+		_ = a.log.Close()
+		a.log = nil
+	}
+}
+
+// talk sends a message to the chat and returns the response.
+// The lock of the agent must be held.
+func (a *Agent) talk(msg string) (string, error) {
+	a.record("<<<<<<<<<<<<<<<<<<<", msg)
+	if _, err := a.chat.Write([]byte(msg)); err != nil {
+		return "", fmt.Errorf("agent: couldn't write message to %s: %w", a.key, err)
+	}
+	buf := make([]byte, 1024*64)
+	n, err := a.chat.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("agent: couldn't read message from %s: %w", a.key, err)
+	}
+	resp := string(buf[:n])
+	a.record(">>>>>>>>>>>>>>>>>>>>", resp)
+	a.messages.Add(1)
+	return resp, nil
+}
+
+func (a *Agent) record(direction, msg string) {
+	if a.log == nil {
+		return
+	}
+	fmt.Fprintf(a.log, "%s: %s\n", time.Now().Format("2006-01-02 15-04-05"), direction)
+	fmt.Fprintln(a.log, msg)
+}
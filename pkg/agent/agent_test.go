@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// echoChat answers each message with the message in upper case.
+type echoChat struct {
+	last   string
+	closed bool
+}
+
+func (c *echoChat) Write(b []byte) (int, error) {
+	c.last = strings.ToUpper(string(b))
+	return len(b), nil
+}
+
+func (c *echoChat) Read(b []byte) (int, error) {
+	return copy(b, c.last), nil
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	var chats []*echoChat
+	m := New(func(ctx context.Context) (io.ReadWriter, func(), error) {
+		c := &echoChat{}
+		chats = append(chats, c)
+		return c, func() { c.closed = true }, nil
+	}, dir, 2)
+
+	key, resp, err := m.Start(ctx, "Web Researcher", "search things", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "web_researcher" || resp != "HELLO" {
+		t.Errorf("unexpected start %q %q", key, resp)
+	}
+	key2, _, err := m.Start(ctx, "web researcher", "other", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key2 != "web_researcher_2" {
+		t.Errorf("unexpected key %q", key2)
+	}
+	if _, _, err := m.Start(ctx, "third", "", "hi"); err == nil {
+		t.Error("expected max agents error")
+	}
+
+	resp, err = m.Message(ctx, key, "bye")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "BYE" {
+		t.Errorf("unexpected response %q", resp)
+	}
+	list := m.List()
+	if len(list) != 2 || list[0].Key != key || list[0].Messages != 2 || list[1].Task != "other" {
+		t.Errorf("unexpected list %+v", list)
+	}
+
+	if err := m.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if !chats[0].closed || chats[1].closed {
+		t.Error("unexpected closed chats")
+	}
+	if _, err := m.Message(ctx, key, "again"); err == nil {
+		t.Error("expected unknown agent error")
+	}
+	if err := m.Delete(key); err == nil {
+		t.Error("expected unknown agent error")
+	}
+	m.Close()
+	if !chats[1].closed || len(m.List()) != 0 {
+		t.Error("agents not closed")
+	}
+
+	// Each agent has its own transcript
+	files, err := filepath.Glob(filepath.Join(dir, "agent_*.txt"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("unexpected transcripts %v: %v", files, err)
+	}
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "HELLO") || !strings.Contains(string(b), "bye") {
+		t.Errorf("unexpected transcript %q", b)
+	}
+}
@@ -0,0 +1,148 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/igolaizola/igogpt/internal/prompt"
+	"github.com/igolaizola/igogpt/pkg/agent"
+)
+
+// StartAgentCommand starts a sub-agent to delegate a task
+type StartAgentCommand struct {
+	agents *agent.Manager
+}
+
+func (c *StartAgentCommand) Name() string {
+	return "start_agent"
+}
+
+func (c *StartAgentCommand) Description() string {
+	return "Start GPT agent"
+}
+
+func (c *StartAgentCommand) Args() []Arg {
+	return []Arg{
+		{Name: "name", Type: TypeString, Required: true, Description: "name of the agent"},
+		{Name: "task", Type: TypeString, Required: true, Description: "short description of the task"},
+		{Name: "prompt", Type: TypeString, Required: true, Description: "first message sent to the agent"},
+	}
+}
+
+// SideEffects returns true, a new chat is created
+func (c *StartAgentCommand) SideEffects() bool {
+	return true
+}
+
+// Mutates returns false, agents only live in the session
+func (c *StartAgentCommand) Mutates(Args) bool {
+	return false
+}
+
+func (c *StartAgentCommand) Run(ctx context.Context, args Args) any {
+	name, task := args.String("name"), args.String("task")
+	msg := fmt.Sprintf(prompt.Agent, name, task, args.String("prompt"))
+	key, resp, err := c.agents.Start(ctx, name, task, msg)
+	if err != nil {
+		return logErr(err)
+	}
+	return map[string]any{
+		"key":      key,
+		"response": resp,
+	}
+}
+
+// MessageAgentCommand sends a message to a sub-agent
+type MessageAgentCommand struct {
+	agents *agent.Manager
+}
+
+func (c *MessageAgentCommand) Name() string {
+	return "message_agent"
+}
+
+func (c *MessageAgentCommand) Description() string {
+	return "Message GPT agent"
+}
+
+func (c *MessageAgentCommand) Args() []Arg {
+	return []Arg{
+		{Name: "key", Type: TypeString, Required: true, Description: "key of the agent"},
+		{Name: "message", Type: TypeString, Required: true, Description: "message to send"},
+	}
+}
+
+// SideEffects returns true, messages change the conversation of the agent
+func (c *MessageAgentCommand) SideEffects() bool {
+	return true
+}
+
+// Mutates returns false, agents only live in the session
+func (c *MessageAgentCommand) Mutates(Args) bool {
+	return false
+}
+
+func (c *MessageAgentCommand) Run(ctx context.Context, args Args) any {
+	resp, err := c.agents.Message(ctx, args.String("key"), args.String("message"))
+	if err != nil {
+		return logErr(err)
+	}
+	return resp
+}
+
+// ListAgentsCommand lists the sub-agents
+type ListAgentsCommand struct {
+	agents *agent.Manager
+}
+
+func (c *ListAgentsCommand) Name() string {
+	return "list_agents"
+}
+
+func (c *ListAgentsCommand) Description() string {
+	return "List GPT agents"
+}
+
+func (c *ListAgentsCommand) Args() []Arg {
+	return nil
+}
+
+func (c *ListAgentsCommand) Run(ctx context.Context, args Args) any {
+	return c.agents.List()
+}
+
+// DeleteAgentCommand deletes a sub-agent
+type DeleteAgentCommand struct {
+	agents *agent.Manager
+}
+
+func (c *DeleteAgentCommand) Name() string {
+	return "delete_agent"
+}
+
+func (c *DeleteAgentCommand) Description() string {
+	return "Delete GPT agent"
+}
+
+func (c *DeleteAgentCommand) Args() []Arg {
+	return []Arg{
+		{Name: "key", Type: TypeString, Required: true, Description: "key of the agent"},
+	}
+}
+
+// SideEffects returns true, the chat of the agent is closed
+func (c *DeleteAgentCommand) SideEffects() bool {
+	return true
+}
+
+// Mutates returns false, agents only live in the session
+func (c *DeleteAgentCommand) Mutates(Args) bool {
+	return false
+}
+
+func (c *DeleteAgentCommand) Run(ctx context.Context, args Args) any {
+	if err := c.agents.Delete(args.String("key")); err != nil {
+		return logErr(err)
+	}
+	return "delete agent success"
+}
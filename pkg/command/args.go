@@ -0,0 +1,224 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ArgType is the type of a command argument.
+type ArgType string
+
+const (
+	TypeString ArgType = "string"
+	TypeInt    ArgType = "integer"
+	TypeBool   ArgType = "boolean"
+)
+
+// Arg describes an argument of a command.
+type Arg struct {
+	Name        string
+	Description string
+	Type        ArgType
+	Required    bool
+	// Default value used when an optional argument is missing
+	Default any
+}
+
+// Args are the validated arguments of a command indexed by name.
+type Args map[string]any
+
+// String returns the string value of the given argument.
+func (a Args) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Int returns the integer value of the given argument.
+func (a Args) Int(name string) int {
+	i, _ := a[name].(int)
+	return i
+}
+
+// Bool returns the boolean value of the given argument.
+func (a Args) Bool(name string) bool {
+	b, _ := a[name].(bool)
+	return b
+}
+
+// Has returns whether the given argument has a value.
+func (a Args) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// ArgError is returned to the AI when the arguments of a command are invalid.
+type ArgError struct {
+	Command string `json:"command"`
+	Arg     string `json:"argument,omitempty"`
+	Reason  string `json:"error"`
+	Usage   string `json:"usage"`
+}
+
+func (e *ArgError) Error() string {
+	if e.Arg == "" {
+		return fmt.Sprintf("command: %s: %s", e.Command, e.Reason)
+	}
+	return fmt.Sprintf("command: %s: argument %q: %s", e.Command, e.Arg, e.Reason)
+}
+
+// Validate validates and coerces the raw arguments of a request against the
+// command arguments.
+// Raw arguments can be positional values or a single object with the values
+// indexed by argument name.
+func Validate(cmd Command, raw []any) (Args, error) {
+	schema := cmd.Args()
+	argErr := func(arg, format string, a ...any) error {
+		return &ArgError{
+			Command: cmd.Name(),
+			Arg:     arg,
+			Reason:  fmt.Sprintf(format, a...),
+			Usage:   Usage(cmd),
+		}
+	}
+
+	// Obtain values by name
+	values := map[string]any{}
+	if named, ok := namedArgs(raw); ok {
+		for k, v := range named {
+			var found bool
+			for _, a := range schema {
+				if strings.EqualFold(a.Name, k) {
+					values[a.Name] = v
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, argErr(k, "unknown argument")
+			}
+		}
+	} else {
+		if len(raw) > len(schema) {
+			return nil, argErr("", "expected at most %d arguments, got %d", len(schema), len(raw))
+		}
+		for i, v := range raw {
+			values[schema[i].Name] = v
+		}
+	}
+
+	// Coerce values to their types
+	args := Args{}
+	for _, a := range schema {
+		v, ok := values[a.Name]
+		if !ok || v == nil {
+			if a.Required {
+				return nil, argErr(a.Name, "missing required argument")
+			}
+			if a.Default != nil {
+				args[a.Name] = a.Default
+			}
+			continue
+		}
+		c, err := coerce(a.Type, v)
+		if err != nil {
+			return nil, argErr(a.Name, "%v", err)
+		}
+		if a.Required && c == "" {
+			return nil, argErr(a.Name, "must not be empty")
+		}
+		args[a.Name] = c
+	}
+	return args, nil
+}
+
+// Schema returns the JSON schema of the arguments of the command.
+func Schema(cmd Command) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for _, a := range cmd.Args() {
+		prop := map[string]any{"type": string(a.Type)}
+		if a.Type == "" {
+			prop["type"] = string(TypeString)
+		}
+		if a.Description != "" {
+			prop["description"] = a.Description
+		}
+		if a.Default != nil {
+			prop["default"] = a.Default
+		}
+		props[a.Name] = prop
+		if a.Required {
+			required = append(required, a.Name)
+		}
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+// namedArgs returns the arguments as a map if they were provided as a single
+// object.
+func namedArgs(raw []any) (map[string]any, bool) {
+	if len(raw) != 1 {
+		return nil, false
+	}
+	m, ok := raw[0].(map[string]any)
+	return m, ok
+}
+
+func coerce(typ ArgType, v any) (any, error) {
+	switch typ {
+	case TypeInt:
+		switch vv := v.(type) {
+		case int:
+			return vv, nil
+		case float64:
+			if vv != float64(int(vv)) {
+				return nil, fmt.Errorf("must be an integer, got %v", vv)
+			}
+			return int(vv), nil
+		case string:
+			i, err := strconv.Atoi(strings.TrimSpace(vv))
+			if err != nil {
+				return nil, fmt.Errorf("must be an integer, got %q", vv)
+			}
+			return i, nil
+		}
+		return nil, fmt.Errorf("must be an integer, got %T", v)
+	case TypeBool:
+		switch vv := v.(type) {
+		case bool:
+			return vv, nil
+		case float64:
+			return vv != 0, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(vv)) {
+			case "true", "yes", "1":
+				return true, nil
+			case "false", "no", "0":
+				return false, nil
+			}
+			return nil, fmt.Errorf("must be a boolean, got %q", vv)
+		}
+		return nil, fmt.Errorf("must be a boolean, got %T", v)
+	default:
+		switch vv := v.(type) {
+		case string:
+			return vv, nil
+		case float64:
+			return strconv.FormatFloat(vv, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(vv), nil
+		}
+		// Objects and arrays are converted to their JSON representation
+		js, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("must be a string, got %T", v)
+		}
+		return string(js), nil
+	}
+}
@@ -0,0 +1,112 @@
+package command
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Decisions recorded in the audit log.
+const (
+	DecisionAllowed   = "allowed"
+	DecisionDenied    = "denied"
+	DecisionRejected  = "rejected"
+	DecisionSimulated = "simulated"
+	DecisionInvalid   = "invalid"
+	DecisionUnknown   = "unknown"
+)
+
+// AuditRecord is the record of an executed command.
+type AuditRecord struct {
+	Time    time.Time `json:"time"`
+	Step    int       `json:"step"`
+	Command string    `json:"command"`
+	Args    any       `json:"args"`
+	// Decision taken before running the command
+	Decision string `json:"decision"`
+	Cached   bool   `json:"cached,omitempty"`
+	// Result of the command, big results are replaced by their hash
+	Result     any    `json:"result,omitempty"`
+	ResultHash string `json:"result_sha256,omitempty"`
+	ResultSize int    `json:"result_size"`
+	Error      string `json:"error,omitempty"`
+	Duration   string `json:"duration"`
+}
+
+// Auditor writes a JSON line for each executed command.
+type Auditor struct {
+	lck       sync.Mutex
+	file      *os.File
+	maxResult int
+}
+
+// NewAuditor creates an audit log in the given directory.
+// Results bigger than max result bytes are replaced by their hash, zero means
+// no limit.
+func NewAuditor(dir string, maxResult int) (*Auditor, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("command: couldn't create audit directory: %w", err)
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("audit_%s.jsonl", time.Now().Format("20060102_150405")))
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("command: couldn't create audit log: %w", err)
+	}
+	return &Auditor{
+		file:      f,
+		maxResult: maxResult,
+	}, nil
+}
+
+// Record writes the record to the audit log.
+func (a *Auditor) Record(rec *AuditRecord) error {
+	if rec.Result != nil {
+		js, err := json.Marshal(rec.Result)
+		if err != nil {
+			return fmt.Errorf("command: couldn't marshal result: %w", err)
+		}
+		rec.ResultSize = len(js)
+		if a.maxResult > 0 && len(js) > a.maxResult {
+			sum := sha256.Sum256(js)
+			rec.ResultHash = hex.EncodeToString(sum[:])
+			rec.Result = nil
+		} else {
+			rec.Result = json.RawMessage(js)
+		}
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("command: couldn't marshal audit record: %w", err)
+	}
+	a.lck.Lock()
+	defer a.lck.Unlock()
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("command: couldn't write audit record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit log.
+func (a *Auditor) Close() error {
+	return a.file.Close()
+}
+
+type stepKey struct{}
+
+// WithStep returns a context that records the given step number in the
+// audit log.
+func WithStep(ctx context.Context, step int) context.Context {
+	return context.WithValue(ctx, stepKey{}, step)
+}
+
+// stepFromContext returns the step number of the context.
+func stepFromContext(ctx context.Context) int {
+	step, _ := ctx.Value(stepKey{}).(int)
+	return step
+}
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := NewAuditor(dir, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &Policy{Default: "allow", Rules: []PolicyRule{{Command: "echo", Action: "deny", Args: map[string]string{"value": "^secret"}}}}
+	if err := policy.compile(); err != nil {
+		t.Fatal(err)
+	}
+	r := New(&Config{Audit: audit, Policy: policy, Output: t.TempDir()})
+	r.Register(&testCommand{
+		name: "echo",
+		run: func(args Args) any {
+			return args.String("value")
+		},
+	})
+	ctx := WithStep(context.Background(), 3)
+	r.Execute(ctx, []CommandRequest{
+		{Name: "echo", Args: []any{"hi"}},
+		{Name: "echo", Args: []any{"a long result that is hashed"}},
+		{Name: "echo", Args: []any{"secret"}},
+		{Name: "echo", Args: []any{"a", "b"}},
+		{Name: "unknown"},
+		{Name: "read", Args: []any{"missing.txt"}},
+	})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "audit_*.jsonl"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("unexpected audit files %v: %v", files, err)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 6 {
+		t.Fatalf("expected 6 records, got %d", len(recs))
+	}
+	for _, rec := range recs {
+		if rec.Step != 3 || rec.Duration == "" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	}
+	if recs[0].Decision != DecisionAllowed || recs[0].Result != "hi" || recs[0].ResultHash != "" {
+		t.Errorf("unexpected record %+v", recs[0])
+	}
+	if recs[1].Result != nil || len(recs[1].ResultHash) != 64 || recs[1].ResultSize != 30 {
+		t.Errorf("unexpected record %+v", recs[1])
+	}
+	if recs[2].Decision != DecisionDenied || recs[2].Error == "" || recs[2].Result != nil {
+		t.Errorf("unexpected record %+v", recs[2])
+	}
+	if recs[3].Decision != DecisionInvalid || recs[4].Decision != DecisionUnknown {
+		t.Errorf("unexpected records %+v %+v", recs[3], recs[4])
+	}
+	if recs[5].Decision != DecisionAllowed || !strings.Contains(recs[5].Error, "missing.txt") {
+		t.Errorf("unexpected record %+v", recs[5])
+	}
+}
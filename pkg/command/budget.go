@@ -0,0 +1,201 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/igolaizola/igogpt/pkg/memory/fixed"
+)
+
+// budgetNote is added to the results truncated to fit the token budget.
+const budgetNote = "result truncated to fit the token budget, the truncated parts are marked with the lines to read next, use more specific commands to get the rest (e.g. read with offset and limit, search)"
+
+// Minimum sizes kept when truncating strings and arrays.
+const (
+	minBudgetString = 100
+	minBudgetItems  = 10
+)
+
+// fitBudget truncates the results so that all together fit in the given
+// number of tokens.
+// The budget is shared fairly: small results are kept intact and the rest of
+// the budget is split between the big ones.
+// The first lines are the lines of the files where the read results start,
+// zero for the rest of results.
+func fitBudget(results []map[string]any, firstLines []int, budget int) []map[string]any {
+	if budget <= 0 || len(results) == 0 {
+		return results
+	}
+	sizes := make([]int, len(results))
+	total := 0
+	for i, r := range results {
+		sizes[i] = countJSON(r)
+		total += sizes[i]
+	}
+	if total <= budget {
+		return results
+	}
+	log.Printf("command: results have %d tokens, truncating to %d\n", total, budget)
+
+	noteTokens := countJSON(budgetNote) + 4
+	shares := shareBudget(sizes, budget)
+	for i, r := range results {
+		if sizes[i] <= shares[i] {
+			continue
+		}
+		tokens := shares[i] - noteTokens
+		if tokens < minBudgetString/4 {
+			tokens = minBudgetString / 4
+		}
+		fitted := map[string]any{"note": budgetNote}
+		for name, v := range r {
+			fitted[name] = fit(v, tokens, firstLines[i])
+		}
+		results[i] = fitted
+	}
+	return results
+}
+
+// shareBudget splits the budget between elements with the given sizes.
+func shareBudget(sizes []int, budget int) []int {
+	idx := make([]int, len(sizes))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		return sizes[idx[a]] < sizes[idx[b]]
+	})
+	shares := make([]int, len(sizes))
+	remaining := budget
+	for n, i := range idx {
+		s := remaining / (len(sizes) - n)
+		if sizes[i] < s {
+			s = sizes[i]
+		}
+		shares[i] = s
+		remaining -= s
+	}
+	return shares
+}
+
+// fit shrinks the strings and arrays of the value until it fits in the given
+// number of tokens.
+// If the value is the content of a file, first line is the line of the file
+// where it starts.
+func fit(v any, tokens, firstLine int) any {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var generic any
+	if err := json.Unmarshal(js, &generic); err != nil {
+		return v
+	}
+	ratio := 1.0
+	for i := 0; i < 20; i++ {
+		n := countJSON(generic)
+		if n <= tokens {
+			return generic
+		}
+		// Always shrink the original value, so that the truncated lines
+		// reported are lines of the original strings
+		ratio *= float64(tokens) / float64(n)
+		var original any
+		_ = json.Unmarshal(js, &original)
+		var shrunk bool
+		generic, shrunk = shrink(original, ratio, firstLine)
+		if !shrunk {
+			break
+		}
+	}
+	// Fallback to truncate the JSON representation
+	js, _ = json.Marshal(generic)
+	return truncateString(string(js), tokens*3, 0)
+}
+
+// shrink reduces the size of the strings and arrays of the value by the given
+// ratio.
+// First line is only used if the value is a string, see truncateString.
+func shrink(v any, ratio float64, firstLine int) (any, bool) {
+	switch vv := v.(type) {
+	case string:
+		runes := []rune(vv)
+		keep := int(float64(len(runes)) * ratio * 0.9)
+		if keep < minBudgetString {
+			keep = minBudgetString
+		}
+		if keep >= len(runes) {
+			return vv, false
+		}
+		return truncateString(vv, keep, firstLine), true
+	case []any:
+		var shrunk bool
+		for i := range vv {
+			var ok bool
+			vv[i], ok = shrink(vv[i], ratio, 0)
+			shrunk = shrunk || ok
+		}
+		keep := int(float64(len(vv)) * ratio * 0.9)
+		if keep < minBudgetItems {
+			keep = minBudgetItems
+		}
+		if keep >= len(vv) {
+			return vv, shrunk
+		}
+		elided := append(vv[:keep:keep], fmt.Sprintf("... %d more items elided", len(vv)-keep))
+		return elided, true
+	case map[string]any:
+		var shrunk bool
+		for k := range vv {
+			var ok bool
+			vv[k], ok = shrink(vv[k], ratio, 0)
+			shrunk = shrunk || ok
+		}
+		return vv, shrunk
+	}
+	return v, false
+}
+
+// truncateString keeps the head and the tail of the string up to the given
+// number of characters.
+// The string is cut at line boundaries if possible and the truncated lines
+// are reported. If the string is the content of a file, first line is the
+// line of the file where it starts and the offset and limit to read the
+// truncated lines are reported too.
+func truncateString(s string, keep, firstLine int) string {
+	runes := []rune(s)
+	if keep >= len(runes) {
+		return s
+	}
+	head := string(runes[:keep*7/10])
+	tail := string(runes[len(runes)-(keep-keep*7/10):])
+	i := strings.LastIndex(head, "\n")
+	j := strings.Index(tail, "\n")
+	if i < 0 || j < 0 {
+		return fmt.Sprintf("%s\n[... %d characters truncated ...]\n%s", head, len(runes)-keep, tail)
+	}
+	head, tail = head[:i+1], tail[j+1:]
+	cut := strings.Count(s[len(head):len(s)-len(tail)], "\n")
+	from := strings.Count(head, "\n") + 1
+	if firstLine <= 0 {
+		return fmt.Sprintf("%s[... lines %d-%d truncated ...]\n%s", head, from, from+cut-1, tail)
+	}
+	from += firstLine - 1
+	return fmt.Sprintf("%s[... lines %d-%d truncated, read them with offset %d and limit %d ...]\n%s", head, from, from+cut-1, from, cut, tail)
+}
+
+func countJSON(v any) int {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	n, err := fixed.Count(string(js))
+	if err != nil {
+		// Approximate the number of tokens
+		return len(js) / 4
+	}
+	return n
+}
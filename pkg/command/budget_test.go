@@ -0,0 +1,116 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFitBudget(t *testing.T) {
+	var files []string
+	for i := 0; i < 1000; i++ {
+		files = append(files, fmt.Sprintf("file%d.txt", i))
+	}
+	small := map[string]any{"write": "write file success"}
+	results := []map[string]any{
+		small,
+		{"read": strings.Repeat("lorem ipsum dolor sit amet ", 1000)},
+		{"list": files},
+	}
+	budget := 500
+	got := fitBudget(results, make([]int, len(results)), budget)
+
+	if !reflect.DeepEqual(got[0], small) {
+		t.Errorf("small result was modified: %v", got[0])
+	}
+	for _, r := range got[1:] {
+		if r["note"] != budgetNote {
+			t.Errorf("missing note in truncated result: %v", r)
+		}
+	}
+	total := 0
+	for _, r := range got {
+		total += countJSON(r)
+	}
+	if total > budget {
+		t.Errorf("results have %d tokens, budget is %d", total, budget)
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	text := strings.Join(lines, "\n") + "\n"
+	tests := []struct {
+		name      string
+		s         string
+		keep      int
+		firstLine int
+		want      string
+	}{
+		{name: "short", s: "hello", keep: 10, want: "hello"},
+		{name: "characters", s: strings.Repeat("a", 20), keep: 10, want: "aaaaaaa\n[... 10 characters truncated ...]\naaa"},
+		{name: "lines", s: text, keep: 100, want: "line 9\n[... lines 10-97 truncated ...]\nline 98\n"},
+		{name: "file", s: text, keep: 100, firstLine: 50, want: "line 9\n[... lines 59-146 truncated, read them with offset 59 and limit 88 ...]\nline 98\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateString(tt.s, tt.keep, tt.firstLine)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBudget(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i := 1; i <= 2000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New(&Config{Output: dir, TokenBudget: 300})
+	read := func(args map[string]any) string {
+		t.Helper()
+		got := r.Execute(context.Background(), []CommandRequest{{Name: "read", Args: []any{args}}})
+		s, ok := got[0]["read"].(string)
+		if !ok {
+			t.Fatalf("unexpected result %v", got)
+		}
+		return s
+	}
+
+	// Offset and limit
+	if got := read(map[string]any{"filename": "a.txt", "offset": 3, "limit": 2}); got != "line 3\nline 4\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := read(map[string]any{"filename": "a.txt", "offset": 1999}); got != "line 1999\nline 2000\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// The truncated lines can be read with the reported offset and limit
+	got := read(map[string]any{"filename": "a.txt", "offset": 101})
+	m := regexp.MustCompile(`\[\.\.\. lines (\d+)-(\d+) truncated, read them with offset (\d+) and limit (\d+) \.\.\.\]\n`).FindStringSubmatch(got)
+	if m == nil {
+		t.Fatalf("truncation marker not found in %q", got)
+	}
+	from, _ := strconv.Atoi(m[1])
+	limit, _ := strconv.Atoi(m[4])
+	if !strings.HasPrefix(got, "line 101\n") || !strings.HasSuffix(got, "line 2000\n") {
+		t.Errorf("head or tail missing in %q", got)
+	}
+	if !strings.Contains(got, fmt.Sprintf("line %d\n[...", from-1)) || !strings.Contains(got, fmt.Sprintf("...]\nline %d\n", from+limit)) {
+		t.Errorf("wrong lines reported in %q", got)
+	}
+}
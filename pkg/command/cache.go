@@ -0,0 +1,159 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Idempotenter is implemented by commands whose results only depend on their
+// arguments.
+// The results of these commands are cached if the runner has a cache.
+type Idempotenter interface {
+	Idempotent() bool
+}
+
+func isIdempotent(cmd Command) bool {
+	i, ok := cmd.(Idempotenter)
+	return ok && i.Idempotent()
+}
+
+// Cache stores the results of idempotent commands during a session.
+// Results are kept for a time to live and optionally persisted to a file, so
+// that they can be reused in later sessions.
+type Cache struct {
+	ttl     time.Duration
+	file    string
+	lck     sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	Result  json.RawMessage `json:"result"`
+	Created time.Time       `json:"created"`
+}
+
+// NewCache creates a cache with the given time to live.
+// If the file isn't empty the cache is loaded from it and every new result
+// is saved to it.
+func NewCache(ttl time.Duration, file string) (*Cache, error) {
+	c := &Cache{
+		ttl:     ttl,
+		file:    file,
+		entries: map[string]cacheEntry{},
+	}
+	if file == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("command: couldn't read cache: %w", err)
+	}
+	if err := json.Unmarshal(b, &c.entries); err != nil {
+		return nil, fmt.Errorf("command: couldn't parse cache %s: %w", file, err)
+	}
+	for k, e := range c.entries {
+		if c.expired(e) {
+			delete(c.entries, k)
+		}
+	}
+	return c, nil
+}
+
+// Get returns the cached result of the command with the given arguments.
+func (c *Cache) Get(name string, args Args) (any, bool) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	key := cacheKey(name, args)
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.expired(e) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	var result any
+	if err := json.Unmarshal(e.Result, &result); err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// Set caches the result of the command with the given arguments.
+func (c *Cache) Set(name string, args Args, result any) error {
+	js, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("command: couldn't marshal result: %w", err)
+	}
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.entries[cacheKey(name, args)] = cacheEntry{
+		Result:  js,
+		Created: time.Now(),
+	}
+	if c.file == "" {
+		return nil
+	}
+	return c.save()
+}
+
+// save writes the entries to the cache file.
+// The lock must be held.
+func (c *Cache) save() error {
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("command: couldn't marshal cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0700); err != nil {
+		return fmt.Errorf("command: couldn't create cache directory: %w", err)
+	}
+	// Write to a temporary file first so the cache is never left corrupted
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("command: couldn't write cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.file); err != nil {
+		return fmt.Errorf("command: couldn't write cache: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) expired(e cacheEntry) bool {
+	return c.ttl > 0 && time.Since(e.Created) > c.ttl
+}
+
+// cacheKey returns the key of a command with its arguments normalized.
+// Spaces at the ends of strings are removed and inner spaces are collapsed.
+func cacheKey(name string, args Args) string {
+	normalized := Args{}
+	for k, v := range args {
+		if s, ok := v.(string); ok {
+			v = strings.Join(strings.Fields(s), " ")
+		}
+		normalized[k] = v
+	}
+	// Map keys are sorted when marshaled
+	js, _ := json.Marshal(normalized)
+	return name + " " + string(js)
+}
+
+// isErrorResult returns whether the result is an error that mustn't be
+// cached.
+func isErrorResult(result any) bool {
+	switch r := result.(type) {
+	case nil, error:
+		return true
+	case map[string]any:
+		_, ok := r["error"]
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type idempotentCommand struct {
+	testCommand
+}
+
+func (c *idempotentCommand) Idempotent() bool { return true }
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := NewCache(time.Hour, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(&Config{Cache: cache})
+	calls := 0
+	r.Register(&idempotentCommand{testCommand{
+		name: "search",
+		run: func(args Args) any {
+			calls++
+			if args.String("value") == "fail" {
+				return logErr(fmt.Errorf("failed"))
+			}
+			return []string{args.String("value"), "result"}
+		},
+	}})
+
+	run := func(value string) map[string]any {
+		t.Helper()
+		return r.Execute(ctx, []CommandRequest{{Name: "search", Args: []any{value}}})[0]
+	}
+	if got := run("golang  docs"); got["cached"] != nil || calls != 1 {
+		t.Fatalf("unexpected first result %v", got)
+	}
+	got := run(" golang docs ")
+	want := map[string]any{"search": []any{"golang  docs", "result"}, "cached": true}
+	if !reflect.DeepEqual(got, want) || calls != 1 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Errors aren't cached
+	run("fail")
+	run("fail")
+	if calls != 3 {
+		t.Errorf("errors were cached, calls = %d", calls)
+	}
+
+	// Results are loaded from the file
+	loaded, err := NewCache(time.Hour, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := loaded.Get("search", Args{"value": "golang docs"}); !ok || !reflect.DeepEqual(v, want["search"]) {
+		t.Errorf("unexpected loaded result %v %v", v, ok)
+	}
+
+	// Expired results are discarded
+	expired, err := NewCache(time.Nanosecond, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := expired.Get("search", Args{"value": "golang docs"}); ok {
+		t.Error("expired result returned")
+	}
+}
@@ -0,0 +1,269 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/igogpt/pkg/shell"
+)
+
+// Runtime describes how to run the source files of a language.
+type Runtime struct {
+	// Command used to run a source file, the path of the file is appended
+	Command []string
+	// Extension of the source files, including the dot
+	Extension string
+}
+
+// DefaultRuntimes are the runtimes available to the run_code command when
+// no runtimes are configured.
+var DefaultRuntimes = map[string]Runtime{
+	"python": {Command: []string{"python3"}, Extension: ".py"},
+	"node":   {Command: []string{"node"}, Extension: ".js"},
+	"go":     {Command: []string{"go", "run"}, Extension: ".go"},
+	"sh":     {Command: []string{"sh"}, Extension: ".sh"},
+}
+
+// runtimeAliases maps alternative language names to runtime names.
+var runtimeAliases = map[string]string{
+	"python3":    "python",
+	"py":         "python",
+	"javascript": "node",
+	"js":         "node",
+	"golang":     "go",
+	"shell":      "sh",
+}
+
+// ParseRuntimes returns the default runtimes updated with the given values.
+// Values are in the format "language=command args", existing languages keep
+// their extension and new ones use the language name as extension.
+func ParseRuntimes(values []string) (map[string]Runtime, error) {
+	runtimes := map[string]Runtime{}
+	for k, v := range DefaultRuntimes {
+		runtimes[k] = v
+	}
+	for _, v := range values {
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("command: invalid runtime %q, expected language=command", v)
+		}
+		name := strings.ToLower(strings.TrimSpace(v[:i]))
+		cmd := strings.Fields(v[i+1:])
+		if len(cmd) == 0 {
+			return nil, fmt.Errorf("command: empty command for runtime %q", name)
+		}
+		rt, ok := runtimes[name]
+		if !ok {
+			rt.Extension = "." + name
+		}
+		rt.Command = cmd
+		runtimes[name] = rt
+	}
+	return runtimes, nil
+}
+
+// RunCodeCommand runs code with the configured language runtimes
+type RunCodeCommand struct {
+	ws        *Workspace
+	exec      shell.Executor
+	runtimes  map[string]Runtime
+	timeout   time.Duration
+	maxOutput int
+	env       []string
+}
+
+func (c *RunCodeCommand) Name() string {
+	return "run_code"
+}
+
+func (c *RunCodeCommand) Description() string {
+	var names []string
+	for name := range c.runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("Run code (%s)", strings.Join(names, ", "))
+}
+
+func (c *RunCodeCommand) Args() []Arg {
+	return []Arg{
+		{Name: "language", Type: TypeString, Description: "language of the code, inferred from the file extension if missing"},
+		{Name: "code", Type: TypeString, Description: "code to run, required if no file is provided"},
+		{Name: "file", Type: TypeString, Description: "path of the file to run, required if no code is provided"},
+	}
+}
+
+// SideEffects returns true, code can modify anything
+func (c *RunCodeCommand) SideEffects() bool {
+	return true
+}
+
+// RunCodeResult is the result of a run_code command.
+type RunCodeResult struct {
+	*shell.Result
+	Tests *TestSummary `json:"tests,omitempty"`
+}
+
+func (c *RunCodeCommand) Run(ctx context.Context, args Args) any {
+	code, file := args.String("code"), args.String("file")
+	if (code == "") == (file == "") {
+		return logErr(fmt.Errorf("either code or file must be provided"))
+	}
+	name, rt, err := c.runtime(args.String("language"), file)
+	if err != nil {
+		return logErr(err)
+	}
+
+	var path string
+	if file != "" {
+		path, err = c.ws.ReadPath(file)
+		if err != nil {
+			return logErr(err)
+		}
+	} else {
+		// Code is saved to a temporary file inside the workspace so it is
+		// also available inside sandboxes
+		if err := os.MkdirAll(c.ws.Root(), 0755); err != nil {
+			return logErr(fmt.Errorf("couldn't create directory: %w", err))
+		}
+		f, err := os.CreateTemp(c.ws.Root(), "run_code_*"+rt.Extension)
+		if err != nil {
+			return logErr(fmt.Errorf("couldn't create code file: %w", err))
+		}
+		path = f.Name()
+		defer os.Remove(path)
+		_, err = f.WriteString(code)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return logErr(fmt.Errorf("couldn't write code file: %w", err))
+		}
+	}
+
+	allow := c.env
+	if len(allow) == 0 {
+		allow = shell.DefaultEnv
+	}
+	cmd := append(append([]string{}, rt.Command...), path)
+	result, err := c.exec.Run(ctx, &shell.Request{
+		Command:   cmd,
+		Dir:       c.ws.Root(),
+		Env:       shell.FilterEnv(os.Environ(), allow),
+		Timeout:   c.timeout,
+		MaxOutput: c.maxOutput,
+	})
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't run %s code: %w", name, err))
+	}
+	if result.ExitCode != 0 {
+		log.Printf("command: run_code exit code %d: %s\n", result.ExitCode, result.Stderr)
+	}
+	return &RunCodeResult{
+		Result: result,
+		Tests:  ParseTestSummary(result.Stdout + "\n" + result.Stderr),
+	}
+}
+
+// runtime returns the runtime of the language, or of the file extension if
+// the language is empty.
+func (c *RunCodeCommand) runtime(lang, file string) (string, Runtime, error) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		ext := strings.ToLower(filepath.Ext(file))
+		for name, rt := range c.runtimes {
+			if ext != "" && rt.Extension == ext {
+				return name, rt, nil
+			}
+		}
+		return "", Runtime{}, fmt.Errorf("couldn't infer the language of %q, provide it", file)
+	}
+	// Configured runtimes take precedence over aliases
+	if _, ok := c.runtimes[lang]; !ok && runtimeAliases[lang] != "" {
+		lang = runtimeAliases[lang]
+	}
+	rt, ok := c.runtimes[lang]
+	if !ok {
+		return "", Runtime{}, fmt.Errorf("unsupported language %q", lang)
+	}
+	return lang, rt, nil
+}
+
+// TestSummary summarizes the results of a test run.
+type TestSummary struct {
+	Framework string   `json:"framework"`
+	Passed    int      `json:"passed"`
+	Failed    int      `json:"failed"`
+	Skipped   int      `json:"skipped"`
+	Failures  []string `json:"failures,omitempty"`
+}
+
+var (
+	goTestLine    = regexp.MustCompile(`(?m)^\s*--- (PASS|FAIL|SKIP): (\S+)`)
+	goPackageLine = regexp.MustCompile(`(?m)^(ok|FAIL)\s+(\S+)\s+(?:[\d.]+s|\(cached\)|\[)`)
+	pytestSummary = regexp.MustCompile(`(?m)^=+ (.*\d+ (?:passed|failed|skipped|errors?).*) in [\d.]+s.*=+\s*$`)
+	pytestCount   = regexp.MustCompile(`(\d+) (passed|failed|skipped|errors?|xfailed|xpassed)`)
+	pytestFailure = regexp.MustCompile(`(?m)^(?:FAILED|ERROR) (\S+)`)
+)
+
+// ParseTestSummary detects the output of go test or pytest and returns a
+// summary of it, or nil if no test output is found.
+// Go tests are counted by test in verbose output and by package otherwise.
+func ParseTestSummary(output string) *TestSummary {
+	if m := pytestSummary.FindAllStringSubmatch(output, -1); len(m) > 0 {
+		s := &TestSummary{Framework: "pytest"}
+		for _, c := range pytestCount.FindAllStringSubmatch(m[len(m)-1][1], -1) {
+			n, _ := strconv.Atoi(c[1])
+			switch c[2] {
+			case "passed", "xpassed", "xfailed":
+				s.Passed += n
+			case "skipped":
+				s.Skipped += n
+			default:
+				s.Failed += n
+			}
+		}
+		for _, f := range pytestFailure.FindAllStringSubmatch(output, -1) {
+			s.Failures = append(s.Failures, f[1])
+		}
+		return s
+	}
+
+	tests := goTestLine.FindAllStringSubmatch(output, -1)
+	pkgs := goPackageLine.FindAllStringSubmatch(output, -1)
+	if len(tests) == 0 && len(pkgs) == 0 {
+		return nil
+	}
+	s := &TestSummary{Framework: "go test"}
+	for _, t := range tests {
+		switch t[1] {
+		case "PASS":
+			s.Passed++
+		case "FAIL":
+			s.Failed++
+			s.Failures = append(s.Failures, t[2])
+		case "SKIP":
+			s.Skipped++
+		}
+	}
+	if len(tests) > 0 {
+		return s
+	}
+	for _, p := range pkgs {
+		if p[1] == "ok" {
+			s.Passed++
+			continue
+		}
+		s.Failed++
+		s.Failures = append(s.Failures, p[2])
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/igolaizola/igogpt/pkg/shell"
+)
+
+func TestRunCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	dir := t.TempDir()
+	runtimes, err := ParseRuntimes([]string{"shell=sh -e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := &RunCodeCommand{ws: NewWorkspace(dir, nil), exec: shell.Direct{}, runtimes: runtimes}
+	run := func(raw map[string]any) any {
+		t.Helper()
+		args, err := Validate(cmd, []any{raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmd.Run(context.Background(), args)
+	}
+
+	result, ok := run(map[string]any{"language": "sh", "code": "echo hello; echo oops >&2; exit 3"}).(*RunCodeResult)
+	if !ok {
+		t.Fatalf("unexpected result %T", result)
+	}
+	if result.Stdout != "hello\n" || result.Stderr != "oops\n" || result.ExitCode != 3 || result.Tests != nil {
+		t.Errorf("unexpected result %+v", result.Result)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("temporary code file not removed")
+	}
+
+	// Language inferred from the file extension
+	if err := os.WriteFile(filepath.Join(dir, "test.sh"), []byte("echo '--- PASS: TestA (0.00s)'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, ok = run(map[string]any{"file": "test.sh"}).(*RunCodeResult)
+	if !ok || result.Tests == nil || result.Tests.Passed != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// Custom runtime
+	result, ok = run(map[string]any{"language": "shell", "code": "false; echo unreachable"}).(*RunCodeResult)
+	if !ok || result.ExitCode == 0 || result.Stdout != "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	for _, raw := range []map[string]any{
+		{"language": "cobol", "code": "x"},
+		{"language": "sh"},
+		{"language": "sh", "code": "x", "file": "test.sh"},
+		{"file": "test.unknown"},
+		{"file": "../test.sh"},
+	} {
+		if errorOf(run(raw)) == "" {
+			t.Errorf("expected error for %v", raw)
+		}
+	}
+}
+
+func TestParseTestSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *TestSummary
+	}{
+		{
+			name:   "no tests",
+			output: "hello world\n",
+		},
+		{
+			name: "go verbose",
+			output: strings.Join([]string{
+				"=== RUN   TestA",
+				"--- PASS: TestA (0.00s)",
+				"=== RUN   TestB",
+				"    --- SKIP: TestB/sub (0.00s)",
+				"--- FAIL: TestB (0.00s)",
+				"FAIL",
+				"FAIL\texample.com/pkg\t0.003s",
+			}, "\n"),
+			want: &TestSummary{Framework: "go test", Passed: 1, Failed: 1, Skipped: 1, Failures: []string{"TestB"}},
+		},
+		{
+			name: "go packages",
+			output: strings.Join([]string{
+				"ok  \texample.com/a\t0.002s",
+				"ok  \texample.com/b\t(cached)",
+				"FAIL\texample.com/c [build failed]",
+			}, "\n"),
+			want: &TestSummary{Framework: "go test", Passed: 2, Failed: 1, Failures: []string{"example.com/c"}},
+		},
+		{
+			name: "pytest",
+			output: strings.Join([]string{
+				"test_a.py ..F.s",
+				"=========================== short test summary info ============================",
+				"FAILED test_a.py::test_three - assert 1 == 2",
+				"==================== 1 failed, 3 passed, 1 skipped in 0.05s ====================",
+			}, "\n"),
+			want: &TestSummary{Framework: "pytest", Passed: 3, Failed: 1, Skipped: 1, Failures: []string{"test_a.py::test_three"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseTestSummary(tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1216 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igolaizola/igogpt/internal/diff"
+	"github.com/igolaizola/igogpt/internal/google"
+	"github.com/igolaizola/igogpt/internal/web"
+	"github.com/igolaizola/igogpt/pkg/agent"
+	"github.com/igolaizola/igogpt/pkg/shell"
+)
+
+// Runner runs the commands registered on it.
+type Runner struct {
+	commands    map[string]Command
+	names       []string
+	concurrency int
+	tokenBudget int
+	policy      *Policy
+	approver    Approver
+	simulator   Simulator
+	cache       *Cache
+	audit       *Auditor
+	logDir      string
+}
+
+// Config represents the configuration for a command runner.
+type Config struct {
+	Exit   func()
+	Output string
+	// Directory where unparseable inputs are saved for debugging, if empty
+	// they aren't saved
+	LogDir string
+	// Maximum number of commands without side effects run concurrently
+	Concurrency int
+	// Maximum number of tokens of the results of each step, zero means no
+	// limit
+	TokenBudget int
+	// Policy enforced before running each command (optional)
+	Policy *Policy
+	// Approver to be called before running each command (optional)
+	Approver Approver
+	// Simulator used instead of running the commands that change state
+	// (optional)
+	Simulator Simulator
+	// Asker used by the ask_user command, if nil the command isn't registered
+	Asker Asker
+	// Time to wait for the answer of the user, zero means no limit
+	AskTimeout time.Duration
+	// Cache of the results of idempotent commands (optional)
+	Cache *Cache
+	// Audit log where every executed command is recorded (optional)
+	Audit *Auditor
+	// Read-only directories exposed inside the output directory, in the
+	// format "name=path" or "path"
+	Mounts []string
+	// Executor used to run bash commands, if nil they run directly in the host
+	Executor shell.Executor
+	// Bash limits, zero values mean no limit
+	BashTimeout   time.Duration
+	BashMaxOutput int
+	// Environment variables passed to bash, if empty shell.DefaultEnv is used
+	BashEnv []string
+	// Runtimes used by the run_code command, if nil DefaultRuntimes are used
+	Runtimes map[string]Runtime
+	// Proxy used by the http command (optional)
+	Proxy string
+	// Domains allowed and denied by the http command, if the allow list is
+	// empty any domain not denied is allowed
+	HTTPAllow []string
+	HTTPDeny  []string
+	// Maximum size in bytes of the downloaded files, zero means no limit
+	DownloadMaxSize int64
+	// Agent manager used by the agent commands, if nil the commands aren't
+	// registered
+	Agents *agent.Manager
+	// Bing chat used by the bing command, if nil the command isn't registered
+	Bing      io.ReadWriter
+	GoogleKey string
+	GoogleCX  string
+}
+
+// New returns a new command runner with the default commands registered.
+func New(cfg *Config) *Runner {
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	r := &Runner{
+		commands:    map[string]Command{},
+		concurrency: concurrency,
+		tokenBudget: cfg.TokenBudget,
+		policy:      cfg.Policy,
+		approver:    cfg.Approver,
+		simulator:   cfg.Simulator,
+		cache:       cfg.Cache,
+		audit:       cfg.Audit,
+		logDir:      cfg.LogDir,
+	}
+	ws := NewWorkspace(cfg.Output, cfg.Mounts)
+	executor := cfg.Executor
+	if executor == nil {
+		executor = shell.Direct{}
+	}
+	httpCmd := NewHTTPCommand(cfg.Proxy, cfg.HTTPAllow, cfg.HTTPDeny)
+	runtimes := cfg.Runtimes
+	if runtimes == nil {
+		runtimes = DefaultRuntimes
+	}
+	git := &gitBase{
+		ws:      ws,
+		exec:    executor,
+		env:     cfg.BashEnv,
+		timeout: cfg.BashTimeout,
+		proxy:   cfg.Proxy,
+	}
+	if cfg.Bing != nil {
+		r.Register(&BingCommand{chat: cfg.Bing})
+	}
+	cmds := []Command{
+		&GoogleCommand{key: cfg.GoogleKey, cx: cfg.GoogleCX},
+		&WebCommand{},
+		httpCmd,
+		&BashCommand{
+			exec:      executor,
+			output:    cfg.Output,
+			timeout:   cfg.BashTimeout,
+			maxOutput: cfg.BashMaxOutput,
+			env:       cfg.BashEnv,
+		},
+		&RunCodeCommand{
+			ws:        ws,
+			exec:      executor,
+			runtimes:  runtimes,
+			timeout:   cfg.BashTimeout,
+			maxOutput: cfg.BashMaxOutput,
+			env:       cfg.BashEnv,
+		},
+		// File commands
+		&WriteFileCommand{ws: ws},
+		&AppendFileCommand{ws: ws},
+		&ReplaceFileCommand{ws: ws},
+		&PatchFileCommand{ws: ws},
+		&ReadFileCommand{ws: ws},
+		&DeleteFileCommand{ws: ws},
+		&ListFilesCommand{ws: ws},
+		&SearchCommand{ws: ws},
+		&DownloadCommand{ws: ws, http: httpCmd, maxSize: cfg.DownloadMaxSize},
+		// Git commands
+		&GitCloneCommand{gitBase: git, http: httpCmd},
+		&GitStatusCommand{gitBase: git},
+		&GitDiffCommand{gitBase: git},
+		&GitCommitCommand{gitBase: git},
+		&GitLogCommand{gitBase: git},
+	}
+	if cfg.Agents != nil {
+		cmds = append(cmds,
+			&StartAgentCommand{agents: cfg.Agents},
+			&MessageAgentCommand{agents: cfg.Agents},
+			&ListAgentsCommand{agents: cfg.Agents},
+			&DeleteAgentCommand{agents: cfg.Agents},
+		)
+	}
+	if cfg.Asker != nil {
+		cmds = append(cmds, &AskUserCommand{asker: cfg.Asker, timeout: cfg.AskTimeout})
+	}
+	cmds = append(cmds,
+		&ExitCommand{exit: cfg.Exit},
+		NewNopCommand("talk"), NewNopCommand("think"),
+	)
+	for _, cmd := range cmds {
+		r.Register(cmd)
+	}
+	return r
+}
+
+// Register adds a command to the runner.
+// If a command with the same name is already registered it is replaced.
+func (r *Runner) Register(cmd Command) {
+	name := cmd.Name()
+	if _, ok := r.commands[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.commands[name] = cmd
+}
+
+// Commands returns the registered commands in registration order.
+func (r *Runner) Commands() []Command {
+	var cmds []Command
+	for _, name := range r.names {
+		cmds = append(cmds, r.commands[name])
+	}
+	return cmds
+}
+
+// Help returns the numbered list of commands to be included in a prompt.
+// Commands without description are omitted.
+func (r *Runner) Help() string {
+	var sb strings.Builder
+	i := 0
+	for _, cmd := range r.Commands() {
+		if cmd.Description() == "" {
+			continue
+		}
+		i++
+		fmt.Fprintf(&sb, "%d. %s: %s\n", i, cmd.Description(), Usage(cmd))
+	}
+	return sb.String()
+}
+
+// Usage returns an example of how to invoke the command in JSON format.
+// Optional arguments are marked as such.
+func Usage(cmd Command) string {
+	var args []string
+	for _, a := range cmd.Args() {
+		name := a.Name
+		if !a.Required {
+			name += " (optional)"
+		}
+		args = append(args, fmt.Sprintf("%q", name))
+	}
+	usage := fmt.Sprintf("[%s]", strings.Join(args, ", "))
+	if len(args) == 1 {
+		usage = args[0]
+	}
+	return fmt.Sprintf("{%q: %s}", cmd.Name(), usage)
+}
+
+// Run runs commands based on an input string.
+func (r *Runner) Run(ctx context.Context, input string) []map[string]any {
+	step, err := r.ParseStep(input)
+	if err != nil {
+		results := []map[string]any{
+			{"error": err.Error()},
+		}
+		return results
+	}
+	return r.Execute(ctx, step.Commands)
+}
+
+// ParseStep parses the thoughts and commands from the input string.
+// If the input can't be parsed it is saved to the log directory for debugging.
+func (r *Runner) ParseStep(input string) (*Step, error) {
+	step, err := ParseStep(input)
+	if err == nil {
+		return step, nil
+	}
+	err = fmt.Errorf("couldn't parse commands: %w", err)
+	log.Println(err)
+	if r.logDir == "" {
+		return nil, err
+	}
+	// Write input to file for debugging, use timestamp to avoid overwriting
+	if err := os.MkdirAll(r.logDir, 0700); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	filename := filepath.Join(r.logDir, fmt.Sprintf("error_%s.json", time.Now().Format("20060102_150405.000")))
+	if err := os.WriteFile(filename, []byte(input), 0600); err != nil {
+		log.Println(err)
+	}
+	return nil, err
+}
+
+// Execute runs the given command requests and returns their results.
+// Commands without results are omitted.
+func (r *Runner) Execute(ctx context.Context, reqs []CommandRequest) []map[string]any {
+	results := r.execute(ctx, reqs)
+	lines := r.firstLines(reqs)
+
+	// Remove empty results keeping the original order
+	filtered := []map[string]any{}
+	var filteredLines []int
+	for i, result := range results {
+		if result != nil {
+			filtered = append(filtered, result)
+			filteredLines = append(filteredLines, lines[i])
+		}
+	}
+	return fitBudget(filtered, filteredLines, r.tokenBudget)
+}
+
+// ExecuteEach is like Execute but it returns one result for each request,
+// in the same order.
+func (r *Runner) ExecuteEach(ctx context.Context, reqs []CommandRequest) []map[string]any {
+	results := r.execute(ctx, reqs)
+	for i, result := range results {
+		if result != nil {
+			continue
+		}
+		name, _, _ := r.lookup(reqs[i].Name)
+		results[i] = map[string]any{name: "done"}
+	}
+	return fitBudget(results, r.firstLines(reqs), r.tokenBudget)
+}
+
+// firstLines returns the lines of the files where the results of the read
+// requests start, zero for the rest of requests.
+func (r *Runner) firstLines(reqs []CommandRequest) []int {
+	lines := make([]int, len(reqs))
+	for i, req := range reqs {
+		_, c, _ := r.lookup(req.Name)
+		cmd, ok := c.(*ReadFileCommand)
+		if !ok {
+			continue
+		}
+		args, err := Validate(cmd, req.Args)
+		if err != nil {
+			continue
+		}
+		lines[i] = 1
+		if offset := args.Int("offset"); offset > 1 {
+			lines[i] = offset
+		}
+	}
+	return lines
+}
+
+func (r *Runner) execute(ctx context.Context, reqs []CommandRequest) []map[string]any {
+	// Commands without side effects run concurrently, the rest of commands
+	// wait for the previous ones to finish and run alone
+	results := make([]map[string]any, len(reqs))
+	workers := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		i, req := i, req
+		if _, cmd, ok := r.lookup(req.Name); !ok || hasSideEffects(cmd) {
+			wg.Wait()
+			results[i] = r.runRequest(ctx, req)
+			continue
+		}
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			results[i] = r.runRequest(ctx, req)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Runner) runRequest(ctx context.Context, req CommandRequest) map[string]any {
+	name, cmd, ok := r.lookup(req.Name)
+	rec := &AuditRecord{
+		Time:     time.Now(),
+		Step:     stepFromContext(ctx),
+		Command:  name,
+		Args:     req.Args,
+		Decision: DecisionAllowed,
+	}
+	if r.audit != nil {
+		defer func() {
+			rec.Duration = time.Since(rec.Time).Round(time.Millisecond).String()
+			if err := r.audit.Record(rec); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+	if !ok {
+		log.Println("command: unknown ", name)
+		rec.Decision = DecisionUnknown
+		rec.Error = fmt.Sprintf("unknown command %q, available commands: %s", req.Name, strings.Join(r.available(), ", "))
+		return map[string]any{"error": rec.Error}
+	}
+	args, err := Validate(cmd, req.Args)
+	if err != nil {
+		log.Println(err)
+		rec.Decision = DecisionInvalid
+		rec.Error = err.Error()
+		return map[string]any{
+			name: err,
+		}
+	}
+	rec.Args = args
+	if denied := r.checkPolicy(name, args, rec); denied != nil {
+		return denied
+	}
+	if r.simulator != nil && mutates(cmd, args) {
+		if simulated, ok := r.simulator.Simulate(ctx, cmd, args); ok {
+			log.Printf("command: %s simulated\n", name)
+			rec.Decision = DecisionSimulated
+			rec.Result = simulated
+			return map[string]any{
+				name: simulated,
+			}
+		}
+	}
+	if r.approver != nil {
+		args, err = r.approver.Approve(ctx, cmd, args)
+		if err != nil {
+			log.Printf("command: %s not approved: %v\n", name, err)
+			rec.Decision = DecisionRejected
+			rec.Error = err.Error()
+			return map[string]any{
+				name: map[string]any{"error": err.Error()},
+			}
+		}
+		rec.Args = args
+
+		// The edited arguments must be allowed too
+		if denied := r.checkPolicy(name, args, rec); denied != nil {
+			return denied
+		}
+	}
+	cache := r.cache != nil && isIdempotent(cmd)
+	if cache {
+		if cached, ok := r.cache.Get(name, args); ok {
+			log.Printf("command: %s result found in cache\n", name)
+			rec.Cached = true
+			rec.Result = cached
+			return map[string]any{
+				name:     cached,
+				"cached": true,
+			}
+		}
+	}
+	cmdResult := cmd.Run(ctx, args)
+	if cmdResult == nil {
+		return nil
+	}
+	rec.Result = cmdResult
+	if isErrorResult(cmdResult) {
+		rec.Error = fmt.Sprint(cmdResult)
+		if m, ok := cmdResult.(map[string]any); ok {
+			rec.Error = fmt.Sprint(m["error"])
+		}
+	}
+	if cache && rec.Error == "" {
+		if err := r.cache.Set(name, args, cmdResult); err != nil {
+			log.Println(err)
+		}
+	}
+	return map[string]any{
+		name: cmdResult,
+	}
+}
+
+// checkPolicy returns the result to be sent if the command is denied by the
+// policy.
+func (r *Runner) checkPolicy(name string, args Args, rec *AuditRecord) map[string]any {
+	if r.policy == nil {
+		return nil
+	}
+	err := r.policy.Check(name, args)
+	if err == nil {
+		return nil
+	}
+	log.Printf("command: %v %v\n", err, args)
+	rec.Decision = DecisionDenied
+	rec.Error = err.Error()
+	return map[string]any{
+		name: map[string]any{"error": err.Error()},
+	}
+}
+
+// Close releases the resources of the runner.
+func (r *Runner) Close() error {
+	if r.audit == nil {
+		return nil
+	}
+	return r.audit.Close()
+}
+
+// lookup returns the command registered with the name and its normalized
+// name.
+// Aliases are only used when no command is registered with the exact name, so
+// custom commands can take their names.
+func (r *Runner) lookup(name string) (string, Command, bool) {
+	name = strings.TrimSpace(name)
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, "-", "_")
+	name = strings.ReplaceAll(name, " ", "_")
+	if cmd, ok := r.commands[name]; ok {
+		return name, cmd, true
+	}
+	name = fixName(name)
+	cmd, ok := r.commands[name]
+	return name, cmd, ok
+}
+
+// available returns the names of the commands listed in the prompt.
+func (r *Runner) available() []string {
+	var names []string
+	for _, cmd := range r.Commands() {
+		if cmd.Description() != "" {
+			names = append(names, cmd.Name())
+		}
+	}
+	return names
+}
+
+// fixName returns the built-in command of a normalized alias.
+func fixName(name string) string {
+	switch name {
+	case "write_file", "write_to_file":
+		return "write"
+	case "append_to_file", "append_file":
+		return "append"
+	case "replace_in_file":
+		return "replace"
+	case "patch_file", "apply_patch":
+		return "patch"
+	case "read_file":
+		return "read"
+	case "delete_file":
+		return "delete"
+	case "list_files":
+		return "list"
+	case "search_files", "grep":
+		return "search"
+	case "download_file", "fetch":
+		return "download"
+	case "clone_repository", "git_clone_repository":
+		return "git_clone"
+	case "ask_human", "ask_operator", "ask":
+		return "ask_user"
+	case "evaluate_code", "execute_code", "execute_python_file":
+		return "run_code"
+	}
+	return name
+}
+
+// logErr logs the error and returns it as an object, so failures can be told
+// apart from successful string results.
+func logErr(err error) map[string]any {
+	log.Println(err)
+	return map[string]any{"error": err.Error()}
+}
+
+// Command is a command that can be registered in a runner.
+type Command interface {
+	// Name returns the name used to invoke the command
+	Name() string
+	// Description returns a short description of the command, commands with
+	// an empty description aren't listed in the prompt
+	Description() string
+	// Args returns the arguments accepted by the command
+	Args() []Arg
+	// Run runs the command with validated arguments and returns the result to
+	// be sent back to the AI
+	Run(ctx context.Context, args Args) any
+}
+
+// Approver approves commands before they are run.
+type Approver interface {
+	// Approve returns the arguments to run the command with, which may be
+	// different from the given ones, or an error if the command is rejected.
+	Approve(ctx context.Context, cmd Command, args Args) (Args, error)
+}
+
+// Simulator simulates commands instead of running them.
+type Simulator interface {
+	// Simulate returns the simulated result of the command, or false if the
+	// command must be run.
+	Simulate(ctx context.Context, cmd Command, args Args) (any, bool)
+}
+
+// Asker asks questions to the operator.
+type Asker interface {
+	// Ask shows the question and waits for the answer of the operator.
+	Ask(ctx context.Context, question string) (string, error)
+}
+
+// SideEffecter is implemented by commands that change state outside of the
+// command itself.
+// These commands keep their order and never run concurrently with others.
+type SideEffecter interface {
+	SideEffects() bool
+}
+
+func hasSideEffects(cmd Command) bool {
+	s, ok := cmd.(SideEffecter)
+	return ok && s.SideEffects()
+}
+
+// Mutater is implemented by commands with side effects that don't always
+// change state outside of the session, like asking bing or sending a GET
+// request.
+// Commands with side effects that don't implement it are considered to change
+// state, so they aren't run in a dry run.
+type Mutater interface {
+	Mutates(args Args) bool
+}
+
+func mutates(cmd Command, args Args) bool {
+	if m, ok := cmd.(Mutater); ok {
+		return m.Mutates(args)
+	}
+	return hasSideEffects(cmd)
+}
+
+// BashCommand executes a bash command
+type BashCommand struct {
+	exec      shell.Executor
+	output    string
+	timeout   time.Duration
+	maxOutput int
+	env       []string
+}
+
+func (c *BashCommand) Name() string {
+	return "bash"
+}
+
+func (c *BashCommand) Description() string {
+	return "Execute bash command"
+}
+
+func (c *BashCommand) Args() []Arg {
+	return []Arg{
+		{Name: "command", Type: TypeString, Required: true, Description: "bash command to execute"},
+	}
+}
+
+// SideEffects returns true, bash commands can modify anything
+func (c *BashCommand) SideEffects() bool {
+	return true
+}
+
+func (c *BashCommand) Run(ctx context.Context, args Args) any {
+	allow := c.env
+	if len(allow) == 0 {
+		allow = shell.DefaultEnv
+	}
+	result, err := c.exec.Run(ctx, &shell.Request{
+		Command:   []string{"bash", "-c", args.String("command")},
+		Dir:       c.output,
+		Env:       shell.FilterEnv(os.Environ(), allow),
+		Timeout:   c.timeout,
+		MaxOutput: c.maxOutput,
+	})
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't execute bash command: %w", err))
+	}
+	if result.ExitCode != 0 {
+		log.Printf("command: bash exit code %d: %s\n", result.ExitCode, result.Stderr)
+	}
+	return result
+}
+
+// BingCommand asks bing chat the given question
+type BingCommand struct {
+	chat io.ReadWriter
+}
+
+func (c *BingCommand) Name() string {
+	return "bing"
+}
+
+func (c *BingCommand) Description() string {
+	return "Ask bing AI"
+}
+
+func (c *BingCommand) Args() []Arg {
+	return []Arg{
+		{Name: "question", Type: TypeString, Required: true, Description: "question to ask bing"},
+	}
+}
+
+// SideEffects returns true, all questions are sent to the same chat
+func (c *BingCommand) SideEffects() bool {
+	return true
+}
+
+// Mutates returns false, only the bing chat of the session changes
+func (c *BingCommand) Mutates(Args) bool {
+	return false
+}
+
+func (c *BingCommand) Run(ctx context.Context, args Args) any {
+	// Send message to bing
+	if _, err := c.chat.Write([]byte(args.String("question"))); err != nil {
+		return logErr(fmt.Errorf("couldn't write message to bing: %w", err))
+	}
+	// Read message from bing
+	bingBuf := make([]byte, 1024*64)
+	bingN, err := c.chat.Read(bingBuf)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't read message from bing: %w", err))
+	}
+	bingRecv := string(bingBuf[:bingN])
+	return bingRecv
+}
+
+type GoogleCommand struct {
+	key string
+	cx  string
+}
+
+func (c *GoogleCommand) Name() string {
+	return "google"
+}
+
+func (c *GoogleCommand) Description() string {
+	return "Google Search"
+}
+
+func (c *GoogleCommand) Args() []Arg {
+	return []Arg{
+		{Name: "query", Type: TypeString, Required: true, Description: "search query"},
+	}
+}
+
+// Idempotent returns true, searches are cached
+func (c *GoogleCommand) Idempotent() bool {
+	return true
+}
+
+func (c *GoogleCommand) Run(ctx context.Context, args Args) any {
+	results, err := google.Search(ctx, c.key, c.cx, args.String("query"))
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't search google: %w", err))
+	}
+	return results
+}
+
+type WebCommand struct{}
+
+func (c *WebCommand) Name() string {
+	return "web"
+}
+
+func (c *WebCommand) Description() string {
+	return "Browse Website"
+}
+
+func (c *WebCommand) Args() []Arg {
+	return []Arg{
+		{Name: "url", Type: TypeString, Required: true, Description: "url of the website"},
+	}
+}
+
+// Idempotent returns true, websites are cached
+func (c *WebCommand) Idempotent() bool {
+	return true
+}
+
+func (c *WebCommand) Run(ctx context.Context, args Args) any {
+	text, err := web.Text(ctx, args.String("url"))
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't obtain web: %w", err))
+	}
+	return text
+}
+
+// NewNopCommand creates a new nop command with the given name
+func NewNopCommand(name string) *nopCommand {
+	return &nopCommand{
+		name: name,
+	}
+}
+
+type nopCommand struct {
+	name string
+}
+
+func (c *nopCommand) Name() string {
+	return c.name
+}
+
+func (c *nopCommand) Description() string {
+	return ""
+}
+
+func (c *nopCommand) Args() []Arg {
+	return []Arg{
+		{Name: "text", Type: TypeString},
+	}
+}
+
+func (c *nopCommand) Run(ctx context.Context, args Args) any {
+	return fmt.Sprintf("received %s command", c.Name())
+}
+
+// ReadFileCommand reads a file from the output directory
+type ReadFileCommand struct {
+	ws *Workspace
+}
+
+func (c *ReadFileCommand) Name() string {
+	return "read"
+}
+
+func (c *ReadFileCommand) Description() string {
+	return "Read file"
+}
+
+func (c *ReadFileCommand) Args() []Arg {
+	return []Arg{
+		{Name: "filename", Type: TypeString, Required: true, Description: "path of the file to read"},
+		{Name: "offset", Type: TypeInt, Description: "line to start reading from, starting at 1"},
+		{Name: "limit", Type: TypeInt, Description: "max number of lines to read"},
+	}
+}
+
+func (c *ReadFileCommand) Run(ctx context.Context, args Args) any {
+	// Read file
+	path, err := c.ws.ReadPath(args.String("filename"))
+	if err != nil {
+		return logErr(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't read file: %w", err))
+	}
+	if !args.Has("offset") && !args.Has("limit") {
+		return string(data)
+	}
+
+	// Read only the requested lines
+	lines := strings.SplitAfter(string(data), "\n")
+	from := args.Int("offset") - 1
+	if from < 0 {
+		from = 0
+	}
+	if from >= len(lines) {
+		return logErr(fmt.Errorf("offset %d exceeds the %d lines of the file", from+1, len(lines)))
+	}
+	to := len(lines)
+	if limit := args.Int("limit"); limit > 0 && from+limit < to {
+		to = from + limit
+	}
+	return strings.Join(lines[from:to], "")
+}
+
+// WriteFileCommand writes a file to the output directory
+type WriteFileCommand struct {
+	ws *Workspace
+}
+
+func (c *WriteFileCommand) Name() string {
+	return "write"
+}
+
+func (c *WriteFileCommand) Description() string {
+	return "Write to file"
+}
+
+func (c *WriteFileCommand) Args() []Arg {
+	return []Arg{
+		{Name: "filename", Type: TypeString, Required: true, Description: "path of the file to write"},
+		{Name: "contents", Type: TypeString, Default: "", Description: "contents of the file"},
+	}
+}
+
+// SideEffects returns true, files are modified
+func (c *WriteFileCommand) SideEffects() bool {
+	return true
+}
+
+func (c *WriteFileCommand) Run(ctx context.Context, args Args) any {
+	path, err := c.ws.Path(args.String("filename"))
+	if err != nil {
+		return logErr(err)
+	}
+	// Create directory if it doesn't exist
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return logErr(fmt.Errorf("couldn't create directory: %w", err))
+	}
+	// Write file
+	if err := os.WriteFile(path, []byte(args.String("contents")), 0644); err != nil {
+		return logErr(fmt.Errorf("couldn't write file: %w", err))
+	}
+	return "write file success"
+}
+
+// AppendFileCommand appends contents to a file in the output directory
+type AppendFileCommand struct {
+	ws *Workspace
+}
+
+func (c *AppendFileCommand) Name() string {
+	return "append"
+}
+
+func (c *AppendFileCommand) Description() string {
+	return "Append to file"
+}
+
+func (c *AppendFileCommand) Args() []Arg {
+	return []Arg{
+		{Name: "filename", Type: TypeString, Required: true, Description: "path of the file to append to"},
+		{Name: "contents", Type: TypeString, Required: true, Description: "contents to append"},
+	}
+}
+
+// SideEffects returns true, files are modified
+func (c *AppendFileCommand) SideEffects() bool {
+	return true
+}
+
+func (c *AppendFileCommand) Run(ctx context.Context, args Args) any {
+	path, err := c.ws.Path(args.String("filename"))
+	if err != nil {
+		return logErr(err)
+	}
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return logErr(fmt.Errorf("couldn't create directory: %w", err))
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't open file: %w", err))
+	}
+	defer f.Close()
+	contents := args.String("contents")
+	if _, err := f.WriteString(contents); err != nil {
+		return logErr(fmt.Errorf("couldn't append to file: %w", err))
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't stat file: %w", err))
+	}
+	return map[string]any{
+		"appended_bytes": len(contents),
+		"size":           info.Size(),
+	}
+}
+
+// ReplaceFileCommand replaces text in a file in the output directory
+type ReplaceFileCommand struct {
+	ws *Workspace
+}
+
+func (c *ReplaceFileCommand) Name() string {
+	return "replace"
+}
+
+func (c *ReplaceFileCommand) Description() string {
+	return "Replace text in file"
+}
+
+func (c *ReplaceFileCommand) Args() []Arg {
+	return []Arg{
+		{Name: "filename", Type: TypeString, Required: true, Description: "path of the file to edit"},
+		{Name: "search", Type: TypeString, Required: true, Description: "exact text to search"},
+		{Name: "replace", Type: TypeString, Default: "", Description: "replacement text"},
+		{Name: "count", Type: TypeInt, Default: 1, Description: "expected number of occurrences, 0 to replace all"},
+	}
+}
+
+// SideEffects returns true, files are modified
+func (c *ReplaceFileCommand) SideEffects() bool {
+	return true
+}
+
+func (c *ReplaceFileCommand) Run(ctx context.Context, args Args) any {
+	path, err := c.ws.Path(args.String("filename"))
+	if err != nil {
+		return logErr(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't read file: %w", err))
+	}
+	text := string(data)
+	search := args.String("search")
+
+	// Obtain the lines where the text is found
+	var lines []int
+	for i := 0; ; {
+		idx := strings.Index(text[i:], search)
+		if idx < 0 {
+			break
+		}
+		lines = append(lines, strings.Count(text[:i+idx], "\n")+1)
+		i += idx + len(search)
+	}
+	if len(lines) == 0 {
+		return logErr(fmt.Errorf("search text not found in %s", args.String("filename")))
+	}
+	if count := args.Int("count"); count > 0 && count != len(lines) {
+		return logErr(fmt.Errorf("found %d occurrences at lines %v, expected %d, provide more context or set the count", len(lines), lines, count))
+	}
+
+	text = strings.ReplaceAll(text, search, args.String("replace"))
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return logErr(fmt.Errorf("couldn't write file: %w", err))
+	}
+	return map[string]any{
+		"replaced": len(lines),
+		"lines":    lines,
+	}
+}
+
+// PatchFileCommand applies a unified diff to a file in the output directory
+type PatchFileCommand struct {
+	ws *Workspace
+}
+
+func (c *PatchFileCommand) Name() string {
+	return "patch"
+}
+
+func (c *PatchFileCommand) Description() string {
+	return "Apply unified diff to file"
+}
+
+func (c *PatchFileCommand) Args() []Arg {
+	return []Arg{
+		{Name: "filename", Type: TypeString, Required: true, Description: "path of the file to patch"},
+		{Name: "diff", Type: TypeString, Required: true, Description: "unified diff of the file with @@ hunks"},
+	}
+}
+
+// SideEffects returns true, files are modified
+func (c *PatchFileCommand) SideEffects() bool {
+	return true
+}
+
+func (c *PatchFileCommand) Run(ctx context.Context, args Args) any {
+	path, err := c.ws.Path(args.String("filename"))
+	if err != nil {
+		return logErr(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return logErr(fmt.Errorf("couldn't read file: %w", err))
+	}
+	text, stats, err := diff.Apply(string(data), args.String("diff"))
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't apply patch, file not modified: %w", err))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return logErr(fmt.Errorf("couldn't create directory: %w", err))
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return logErr(fmt.Errorf("couldn't write file: %w", err))
+	}
+	return stats
+}
+
+// DeleteFileCommand deletes a file from the output directory
+type DeleteFileCommand struct {
+	ws *Workspace
+}
+
+func (c *DeleteFileCommand) Name() string {
+	return "delete"
+}
+
+func (c *DeleteFileCommand) Description() string {
+	return "Delete file"
+}
+
+func (c *DeleteFileCommand) Args() []Arg {
+	return []Arg{
+		{Name: "filename", Type: TypeString, Required: true, Description: "path of the file to delete"},
+	}
+}
+
+// SideEffects returns true, files are removed
+func (c *DeleteFileCommand) SideEffects() bool {
+	return true
+}
+
+func (c *DeleteFileCommand) Run(ctx context.Context, args Args) any {
+	// Delete file
+	path, err := c.ws.Path(args.String("filename"))
+	if err != nil {
+		return logErr(err)
+	}
+	if path == c.ws.Root() {
+		return logErr(fmt.Errorf("couldn't delete the workspace root"))
+	}
+	if err := os.Remove(path); err != nil {
+		return logErr(fmt.Errorf("couldn't delete file: %w", err))
+	}
+	return "delete file success"
+}
+
+// ListFilesCommand lists files in the output directory
+type ListFilesCommand struct {
+	ws *Workspace
+}
+
+func (c *ListFilesCommand) Name() string {
+	return "list"
+}
+
+func (c *ListFilesCommand) Description() string {
+	return "List files"
+}
+
+func (c *ListFilesCommand) Args() []Arg {
+	return []Arg{
+		{Name: "directory", Type: TypeString, Default: ".", Description: "directory to list"},
+	}
+}
+
+func (c *ListFilesCommand) Run(ctx context.Context, args Args) any {
+	// List files
+	path, err := c.ws.ReadPath(args.String("directory"))
+	if err != nil {
+		return logErr(err)
+	}
+	var items []string
+	err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			name += "/"
+		}
+		items = append(items, name)
+		return nil
+	})
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't list files: %w", err))
+	}
+	// Show read-only mounts when listing the workspace root
+	if path == c.ws.Root() {
+		for _, name := range c.ws.Mounts() {
+			items = append(items, name+"/ (read-only)")
+		}
+	}
+	return items
+}
+
+// AskUserCommand asks the operator a question and waits for the answer
+type AskUserCommand struct {
+	asker   Asker
+	timeout time.Duration
+}
+
+func (c *AskUserCommand) Name() string {
+	return "ask_user"
+}
+
+func (c *AskUserCommand) Description() string {
+	return "Ask the user (only if you can't continue without help)"
+}
+
+func (c *AskUserCommand) Args() []Arg {
+	return []Arg{
+		{Name: "question", Type: TypeString, Required: true, Description: "question to ask"},
+	}
+}
+
+// SideEffects returns true, it blocks until the user answers
+func (c *AskUserCommand) SideEffects() bool {
+	return true
+}
+
+// Mutates returns false, nothing outside of the session changes
+func (c *AskUserCommand) Mutates(Args) bool {
+	return false
+}
+
+func (c *AskUserCommand) Run(ctx context.Context, args Args) any {
+	askCtx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		askCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	answer, err := c.asker.Ask(askCtx, fmt.Sprintf("\nthe AI asks: %s\nanswer: ", args.String("question")))
+	if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return "the user didn't answer in time, continue on your own"
+	}
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't ask the user: %w", err))
+	}
+	if strings.TrimSpace(answer) == "" {
+		return "the user didn't answer, continue on your own"
+	}
+	return answer
+}
+
+type ExitCommand struct {
+	exit func()
+}
+
+func (c *ExitCommand) Name() string {
+	return "exit"
+}
+
+func (c *ExitCommand) Description() string {
+	return "Exit (Task completed)"
+}
+
+func (c *ExitCommand) Args() []Arg {
+	return []Arg{
+		{Name: "reason", Type: TypeString, Description: "reason to exit"},
+	}
+}
+
+// SideEffects returns true, it ends the session
+func (c *ExitCommand) SideEffects() bool {
+	return true
+}
+
+// Mutates returns false, only the session ends
+func (c *ExitCommand) Mutates(Args) bool {
+	return false
+}
+
+func (c *ExitCommand) Run(ctx context.Context, args Args) any {
+	if c.exit != nil {
+		c.exit()
+	}
+	return "exit"
+}
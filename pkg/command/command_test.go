@@ -0,0 +1,576 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/igogpt/internal/diff"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []CommandRequest
+	}{
+		{
+			name:  "not-array",
+			input: `[{"talk":"blah"}]`,
+			want:  []CommandRequest{{Name: "talk", Args: []any{"blah"}}},
+		},
+		{
+			name:  "separated-arrays",
+			input: `[{"talk":"blah"}] [{"talk":"blah"}] [{"talk":"blah"}]`,
+			want: []CommandRequest{
+				{Name: "talk", Args: []any{"blah"}},
+				{Name: "talk", Args: []any{"blah"}},
+				{Name: "talk", Args: []any{"blah"}},
+			},
+		},
+		{
+			name:  "write",
+			input: `[{"write": ["file.txt", "hello world"]}]`,
+			want: []CommandRequest{
+				{Name: "write", Args: []any{"file.txt", "hello world"}},
+			},
+		},
+		{
+			name: "envelope",
+			input: `{
+    "thoughts": {
+        "text": "thought",
+        "plan": "- a\n- b"
+    },
+    "commands": [
+        {"google": "golang"},
+        {"write": ["file.txt", "hello"]}
+    ]
+}`,
+			want: []CommandRequest{
+				{Name: "google", Args: []any{"golang"}},
+				{Name: "write", Args: []any{"file.txt", "hello"}},
+			},
+		},
+		{
+			name:  "code-fence",
+			input: "Here are the commands:\n```json\n" + `[{"list": "."}]` + "\n```\nDone.",
+			want: []CommandRequest{
+				{Name: "list", Args: []any{"."}},
+			},
+		},
+		{
+			name: "trailing-commas",
+			input: `{
+    "thoughts": {
+        "speak": "summary",
+    },
+    "commands": [
+        {"read": "a.txt"},
+    ],
+}`,
+			want: []CommandRequest{
+				{Name: "read", Args: []any{"a.txt"}},
+			},
+		},
+		{
+			name:  "single-quotes",
+			input: `[{'write': ['file.txt', 'don't panic']}]`,
+			want: []CommandRequest{
+				{Name: "write", Args: []any{"file.txt", "don't panic"}},
+			},
+		},
+		{
+			name:  "unescaped-newlines",
+			input: "[{\"write\": [\"main.go\", \"package main\n\nfunc main() {}\n\"]}]",
+			want: []CommandRequest{
+				{Name: "write", Args: []any{"main.go", "package main\n\nfunc main() {}\n"}},
+			},
+		},
+		{
+			name:  "unescaped-quotes",
+			input: `[{"bash": "echo "hello world""}]`,
+			want: []CommandRequest{
+				{Name: "bash", Args: []any{`echo "hello world"`}},
+			},
+		},
+		{
+			name:  "multiple-blocks",
+			input: "First I search:\n" + `{"google": "golang"}` + "\nThen I save it:\n" + `{"write": {"filename": "a.txt", "contents": "b"}}`,
+			want: []CommandRequest{
+				{Name: "google", Args: []any{"golang"}},
+				{Name: "write", Args: []any{map[string]any{"filename": "a.txt", "contents": "b"}}},
+			},
+		},
+		{
+			name:  "nested-objects",
+			input: `[{"write": ["data.json", {"a": {"b": [1, 2]}}]}, {"talk": "x"}]`,
+			want: []CommandRequest{
+				{Name: "write", Args: []any{"data.json", map[string]any{"a": map[string]any{"b": []any{1.0, 2.0}}}}},
+				{Name: "talk", Args: []any{"x"}},
+			},
+		},
+		{
+			name:  "name-args",
+			input: `{"command": {"name": "google", "args": {"query": "golang"}}}`,
+			want: []CommandRequest{
+				{Name: "google", Args: []any{map[string]any{"query": "golang"}}},
+			},
+		},
+		{
+			name:  "broken-block-skipped",
+			input: "{\"a\": 1 \"b\": {\"bash\": \"rm -rf x\"}}\nfixed: [{\"list\": \".\"}]",
+			want: []CommandRequest{
+				{Name: "list", Args: []any{"."}},
+			},
+		},
+		{
+			name:  "comments-and-keys",
+			input: `[{write: ["a.txt", "b"]} /* save */, {exit: null} // done` + "\n]",
+			want: []CommandRequest{
+				{Name: "write", Args: []any{"a.txt", "b"}},
+				{Name: "exit", Args: []any{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		line    int
+		column  int
+		excerpt string
+	}{
+		{
+			name:    "missing-comma",
+			input:   "{\n  \"read\": \"a.txt\"\n  \"list\": \".\"\n}",
+			line:    3,
+			column:  3,
+			excerpt: "   2 |   \"read\": \"a.txt\"\n   3 |   \"list\": \".\"\n     |   ^",
+		},
+		{
+			name:   "unterminated",
+			input:  `{"commands": [{"read": "a.txt"`,
+			line:   1,
+			column: 31,
+		},
+		{
+			name:   "truncated-nested",
+			input:  `{"thoughts": {"text": "x", "plan": "y"}, "commands": [{"bash": "ls"}`,
+			line:   1,
+			column: 69,
+		},
+		{
+			// The commands inside a broken envelope aren't run
+			name:   "broken-envelope",
+			input:  `{"thoughts": {"text": "oops" "commands": [{"list": "."}, {"read": "a.txt"}]}`,
+			line:   1,
+			column: 40,
+		},
+		{
+			name:  "no-json",
+			input: "I don't know what to do",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			perr, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("expected parse error, got %v", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column {
+				t.Errorf("error at %d:%d, want %d:%d (%v)", perr.Line, perr.Column, tt.line, tt.column, perr)
+			}
+			if tt.excerpt != "" && perr.Excerpt != tt.excerpt {
+				t.Errorf("excerpt = %q, want %q", perr.Excerpt, tt.excerpt)
+			}
+		})
+	}
+}
+
+func TestParseStep(t *testing.T) {
+	input := `{
+    "thoughts": {
+        "text": "list the files",
+        "reasoning": "to know what exists",
+        "plan": ["list files", "- read main.go"],
+        "criticism": "",
+        "speak": "listing files"
+    },
+    "commands": [{"list": "."}, {"think": "maybe read later"}]
+}`
+	got, err := ParseStep(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Step{
+		Thoughts: Thoughts{
+			Text:      "list the files",
+			Reasoning: "to know what exists",
+			Plan:      "- list files\n- read main.go",
+			Speak:     "listing files",
+		},
+		Commands: []CommandRequest{
+			{Name: "list", Args: []any{"."}},
+			{Name: "think", Args: []any{"maybe read later"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStep() = %+v, want %+v", got, want)
+	}
+
+	// Talk and think are used when thoughts are missing
+	got, err = ParseStep(`[{"think": "hmm"}, {"talk": "hello"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Thoughts.Text != "hmm" || got.Thoughts.Speak != "hello" {
+		t.Errorf("ParseStep() thoughts = %+v", got.Thoughts)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cmd := &WriteFileCommand{}
+	tests := []struct {
+		name    string
+		args    []any
+		want    Args
+		wantArg string
+	}{
+		{
+			name: "positional",
+			args: []any{"file.txt", "hello world"},
+			want: Args{"filename": "file.txt", "contents": "hello world"},
+		},
+		{
+			name: "named",
+			args: []any{map[string]any{"filename": "file.txt", "contents": "hello world"}},
+			want: Args{"filename": "file.txt", "contents": "hello world"},
+		},
+		{
+			name: "default",
+			args: []any{"file.txt"},
+			want: Args{"filename": "file.txt", "contents": ""},
+		},
+		{
+			name: "coerce",
+			args: []any{"file.json", map[string]any{"a": 1.0}},
+			want: Args{"filename": "file.json", "contents": `{"a":1}`},
+		},
+		{
+			name:    "missing",
+			args:    []any{},
+			wantArg: "filename",
+		},
+		{
+			name:    "unknown",
+			args:    []any{map[string]any{"file": "file.txt"}},
+			wantArg: "file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validate(cmd, tt.args)
+			if tt.wantArg != "" {
+				argErr, ok := err.(*ArgError)
+				if !ok {
+					t.Fatalf("expected arg error, got %v", err)
+				}
+				if argErr.Arg != tt.wantArg {
+					t.Errorf("Validate() arg = %s, want %s", argErr.Arg, tt.wantArg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type testCommand struct {
+	name        string
+	sideEffects bool
+	run         func(args Args) any
+}
+
+func (c *testCommand) Name() string        { return c.name }
+func (c *testCommand) Description() string { return c.name }
+func (c *testCommand) SideEffects() bool   { return c.sideEffects }
+func (c *testCommand) Args() []Arg {
+	return []Arg{{Name: "value", Type: TypeString}}
+}
+func (c *testCommand) Run(ctx context.Context, args Args) any {
+	return c.run(args)
+}
+
+func TestExecute(t *testing.T) {
+	r := New(&Config{Concurrency: 4})
+	var running, maxRunning int32
+	var order []string
+	var lck sync.Mutex
+	r.Register(&testCommand{
+		name: "slow",
+		run: func(args Args) any {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			lck.Lock()
+			if n > maxRunning {
+				maxRunning = n
+			}
+			lck.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			return args.String("value")
+		},
+	})
+	r.Register(&testCommand{
+		name:        "effect",
+		sideEffects: true,
+		run: func(args Args) any {
+			if n := atomic.LoadInt32(&running); n != 0 {
+				t.Errorf("side effect command running with %d commands", n)
+			}
+			lck.Lock()
+			order = append(order, args.String("value"))
+			lck.Unlock()
+			return args.String("value")
+		},
+	})
+	reqs := []CommandRequest{
+		{Name: "slow", Args: []any{"1"}},
+		{Name: "slow", Args: []any{"2"}},
+		{Name: "effect", Args: []any{"3"}},
+		{Name: "slow", Args: []any{"4"}},
+		{Name: "slow", Args: []any{"5"}},
+		{Name: "slow", Args: []any{"6"}},
+		{Name: "effect", Args: []any{"7"}},
+	}
+	got := r.Execute(context.Background(), reqs)
+	var want []map[string]any
+	for _, req := range reqs {
+		want = append(want, map[string]any{req.Name: req.Args[0]})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Execute() = %v, want %v", got, want)
+	}
+	if maxRunning < 2 {
+		t.Errorf("commands didn't run concurrently")
+	}
+	if !reflect.DeepEqual(order, []string{"3", "7"}) {
+		t.Errorf("side effect order = %v", order)
+	}
+}
+
+func TestExecuteEach(t *testing.T) {
+	r := New(&Config{})
+	r.Register(&testCommand{
+		name: "echo",
+		run: func(args Args) any {
+			return args.String("value")
+		},
+	})
+	reqs := []CommandRequest{
+		{Name: "echo", Args: []any{map[string]any{"value": "a"}}},
+		{Name: "unknown"},
+		{Name: "think", Args: []any{"hmm"}},
+	}
+	unknown := map[string]any{"error": `unknown command "unknown", available commands: ` + strings.Join(r.available(), ", ")}
+	got := r.ExecuteEach(context.Background(), reqs)
+	want := []map[string]any{
+		{"echo": "a"},
+		unknown,
+		{"think": "received think command"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExecuteEach() = %v, want %v", got, want)
+	}
+
+	// Unknown commands are reported in text mode too
+	got = r.Execute(context.Background(), reqs[:2])
+	if !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("Execute() = %v, want %v", got, want[:2])
+	}
+	if msg := errorOf(unknown); !strings.Contains(msg, "echo") || strings.Contains(msg, "think") {
+		t.Errorf("unexpected available commands %q", msg)
+	}
+}
+
+func TestExecuteAlias(t *testing.T) {
+	r := New(&Config{Output: t.TempDir()})
+	r.Register(&testCommand{
+		name: "grep",
+		run: func(args Args) any {
+			return "custom grep"
+		},
+	})
+	reqs := []CommandRequest{
+		{Name: "Grep", Args: []any{"x"}},
+		{Name: "write_file", Args: []any{"a.txt", "hi"}},
+	}
+	got := r.Execute(context.Background(), reqs)
+	want := []map[string]any{
+		{"grep": "custom grep"},
+		{"write": "write file success"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Execute() = %v, want %v", got, want)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	got := Schema(&ReadFileCommand{})
+	props := got["properties"].(map[string]any)
+	want := map[string]any{"type": "string", "description": "path of the file to read"}
+	if !reflect.DeepEqual(props["filename"], want) {
+		t.Errorf("Schema() filename = %v", props["filename"])
+	}
+	if !reflect.DeepEqual(got["required"], []string{"filename"}) {
+		t.Errorf("Schema() required = %v", got["required"])
+	}
+	if _, ok := props["offset"]; !ok {
+		t.Errorf("Schema() missing optional offset argument")
+	}
+}
+
+func TestFileCommands(t *testing.T) {
+	dir := t.TempDir()
+	r := New(&Config{Output: dir})
+	cmds := map[string]Command{}
+	for _, c := range r.Commands() {
+		cmds[c.Name()] = c
+	}
+	run := func(name string, raw map[string]any) any {
+		t.Helper()
+		args, err := Validate(cmds[name], []any{raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmds[name].Run(context.Background(), args)
+	}
+	check := func(want string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("got %q, want %q", data, want)
+		}
+	}
+
+	got := run("append", map[string]any{"filename": "a.txt", "contents": "one\ntwo\n"})
+	if want := map[string]any{"appended_bytes": 8, "size": int64(8)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("append = %v, want %v", got, want)
+	}
+	run("append", map[string]any{"filename": "a.txt", "contents": "two\n"})
+	check("one\ntwo\ntwo\n")
+
+	// Replace requires the expected count of occurrences
+	if msg := errorOf(run("replace", map[string]any{"filename": "a.txt", "search": "two", "replace": "2"})); !strings.Contains(msg, "found 2 occurrences at lines [2 3]") {
+		t.Errorf("unexpected replace error %q", msg)
+	}
+	got = run("replace", map[string]any{"filename": "a.txt", "search": "two", "replace": "2", "count": 0})
+	if want := map[string]any{"replaced": 2, "lines": []int{2, 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replace = %v, want %v", got, want)
+	}
+	if msg := errorOf(run("replace", map[string]any{"filename": "a.txt", "search": "missing"})); !strings.Contains(msg, "not found") {
+		t.Errorf("unexpected replace error %q", msg)
+	}
+	check("one\n2\n2\n")
+
+	got = run("patch", map[string]any{"filename": "a.txt", "diff": "@@ -1,2 +1,2 @@\n-one\n+1\n 2\n"})
+	if s, ok := got.(*diff.Stats); !ok || s.Hunks != 1 || s.Added != 1 || s.Removed != 1 {
+		t.Errorf("unexpected patch result %v", got)
+	}
+	check("1\n2\n2\n")
+
+	// Failed patches don't modify the file
+	for _, patch := range []string{
+		"@@ -1,1 +1,1 @@\n-missing\n+x\n",
+		"--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-1\n+one\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-x\n+y\n",
+	} {
+		if msg := errorOf(run("patch", map[string]any{"filename": "a.txt", "diff": patch})); !strings.Contains(msg, "file not modified") {
+			t.Errorf("unexpected patch error %q", msg)
+		}
+	}
+	check("1\n2\n2\n")
+
+	// Paths outside the workspace are rejected
+	for name, raw := range map[string]map[string]any{
+		"append":  {"filename": "../a.txt", "contents": "x"},
+		"replace": {"filename": "../a.txt", "search": "x"},
+		"patch":   {"filename": "../a.txt", "diff": "@@\n+x\n"},
+	} {
+		if msg := errorOf(run(name, raw)); !strings.Contains(msg, "outside the workspace") {
+			t.Errorf("%s: expected path error, got %q", name, msg)
+		}
+	}
+}
+
+type askerFunc func(ctx context.Context, question string) (string, error)
+
+func (f askerFunc) Ask(ctx context.Context, question string) (string, error) {
+	return f(ctx, question)
+}
+
+func TestAskUser(t *testing.T) {
+	ctx := context.Background()
+	var answer string
+	runner := New(&Config{
+		Output:     t.TempDir(),
+		AskTimeout: 50 * time.Millisecond,
+		Asker: askerFunc(func(ctx context.Context, question string) (string, error) {
+			if answer == "" {
+				<-ctx.Done()
+				return "", ctx.Err()
+			}
+			return answer, nil
+		}),
+	})
+	ask := func() any {
+		t.Helper()
+		req := CommandRequest{Name: "ask_user", Args: []any{map[string]any{"question": "which file?"}}}
+		return runner.Execute(ctx, []CommandRequest{req})[0]["ask_user"]
+	}
+
+	answer = "main.go"
+	if got := ask(); got != "main.go" {
+		t.Errorf("unexpected answer %v", got)
+	}
+
+	// Unanswered questions return a fallback answer after the timeout
+	answer = ""
+	if got := ask(); !strings.Contains(got.(string), "continue on your own") {
+		t.Errorf("unexpected answer %v", got)
+	}
+}
+
+// errorOf returns the error message of a failed command result.
+func errorOf(result any) string {
+	m, _ := result.(map[string]any)
+	msg, _ := m["error"].(string)
+	return msg
+}
@@ -0,0 +1,251 @@
+package command
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// downloadIdleTimeout is the maximum time waiting for data during a download.
+const downloadIdleTimeout = 30 * time.Second
+
+// DownloadCommand downloads remote files into the output directory.
+// Partial downloads are kept with a ".part" suffix and resumed on retry.
+type DownloadCommand struct {
+	ws      *Workspace
+	http    *HTTPCommand
+	maxSize int64
+	// Maximum time waiting for data, if zero downloadIdleTimeout is used
+	idle time.Duration
+}
+
+func (c *DownloadCommand) Name() string {
+	return "download"
+}
+
+func (c *DownloadCommand) Description() string {
+	return "Download file"
+}
+
+func (c *DownloadCommand) Args() []Arg {
+	return []Arg{
+		{Name: "url", Type: TypeString, Required: true, Description: "url of the file"},
+		{Name: "filename", Type: TypeString, Description: "path where the file is saved, defaults to the name in the url"},
+		{Name: "max_size", Type: TypeInt, Description: "max size in bytes of the file"},
+		{Name: "sha256", Type: TypeString, Description: "expected sha256 checksum of the file"},
+	}
+}
+
+func (c *DownloadCommand) SideEffects() bool {
+	return true
+}
+
+// DownloadResult is the result of a download command.
+type DownloadResult struct {
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+	SHA256      string `json:"sha256"`
+	Resumed     bool   `json:"resumed,omitempty"`
+}
+
+func (c *DownloadCommand) Run(ctx context.Context, args Args) any {
+	u := args.String("url")
+	if !strings.Contains(u, "://") {
+		u = "https://" + u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return logErr(fmt.Errorf("invalid url: %w", err))
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return logErr(fmt.Errorf("invalid url scheme %q", parsed.Scheme))
+	}
+	if err := c.http.checkDomain(parsed); err != nil {
+		return logErr(err)
+	}
+	filename := args.String("filename")
+	if filename == "" {
+		filename = path.Base(parsed.Path)
+		if filename == "." || filename == "/" {
+			filename = "download"
+		}
+	}
+	file, err := c.ws.Path(filename)
+	if err != nil {
+		return logErr(err)
+	}
+	maxSize := c.maxSize
+	if s := int64(args.Int("max_size")); s > 0 && (maxSize <= 0 || s < maxSize) {
+		maxSize = s
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return logErr(fmt.Errorf("couldn't create directory: %w", err))
+	}
+
+	// Resume a previous partial download
+	part := file + ".part"
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	// Big downloads can take long, so instead of a total timeout the download
+	// is canceled when no data is received for a while
+	idle := c.idle
+	if idle <= 0 {
+		idle = downloadIdleTimeout
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stalled int32
+	timer := time.AfterFunc(idle, func() {
+		atomic.StoreInt32(&stalled, 1)
+		cancel()
+	})
+	defer timer.Stop()
+	wrap := func(err error) error {
+		if atomic.LoadInt32(&stalled) == 1 {
+			return fmt.Errorf("no data received for %s", idle)
+		}
+		return err
+	}
+	client := *c.http.client
+	client.Timeout = 0
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't create request: %w", err))
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't download file: %w", wrap(err)))
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file may be already complete
+		if size, ok := rangeSize(resp.Header.Get("Content-Range")); !ok || size != offset {
+			_ = os.Remove(part)
+			return logErr(fmt.Errorf("couldn't resume download, the partial file of %d bytes doesn't match the remote file, retry to download it again", offset))
+		}
+	case resp.StatusCode == http.StatusOK:
+		flags |= os.O_TRUNC
+		offset = 0
+	default:
+		return logErr(fmt.Errorf("couldn't download file: status %d", resp.StatusCode))
+	}
+	if maxSize > 0 && resp.ContentLength > 0 && offset+resp.ContentLength > maxSize {
+		return logErr(fmt.Errorf("file size %d exceeds the limit of %d bytes", offset+resp.ContentLength, maxSize))
+	}
+
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		f, err := os.OpenFile(part, flags, 0644)
+		if err != nil {
+			return logErr(fmt.Errorf("couldn't create file: %w", err))
+		}
+		var body io.Reader = &idleReader{r: resp.Body, timer: timer, idle: idle}
+		if maxSize > 0 {
+			body = io.LimitReader(body, maxSize-offset+1)
+		}
+		n, err := io.Copy(f, body)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			// The partial file is kept to resume the download on retry
+			return logErr(fmt.Errorf("couldn't download file, retry to resume: %w", wrap(err)))
+		}
+		if maxSize > 0 && offset+n > maxSize {
+			_ = os.Remove(part)
+			return logErr(fmt.Errorf("file exceeds the limit of %d bytes", maxSize))
+		}
+	}
+
+	// Verify the checksum
+	sum, size, err := fileSHA256(part)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't read file: %w", err))
+	}
+	if want := strings.ToLower(strings.TrimSpace(args.String("sha256"))); want != "" && want != sum {
+		_ = os.Remove(part)
+		return logErr(fmt.Errorf("checksum mismatch: expected %s, got %s", want, sum))
+	}
+	if err := os.Rename(part, file); err != nil {
+		return logErr(fmt.Errorf("couldn't save file: %w", err))
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mt
+	}
+	return &DownloadResult{
+		Filename:    c.ws.Rel(file),
+		Size:        size,
+		ContentType: contentType,
+		SHA256:      sum,
+		Resumed:     offset > 0,
+	}
+}
+
+// idleReader resets the idle timer each time data is read.
+type idleReader struct {
+	r     io.Reader
+	timer *time.Timer
+	idle  time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.idle)
+	}
+	return n, err
+}
+
+// rangeSize returns the complete size of a Content-Range header, e.g.
+// "bytes */1234".
+func rangeSize(header string) (int64, bool) {
+	i := strings.LastIndex(header, "/")
+	if i < 0 {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(header[i+1:]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+func fileSHA256(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,164 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ws := NewWorkspace(dir, nil)
+	cmd := &DownloadCommand{ws: ws, http: NewHTTPCommand("", nil, nil), maxSize: 20000}
+	run := func(raw map[string]any) any {
+		t.Helper()
+		args, err := Validate(cmd, []any{raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmd.Run(context.Background(), args)
+	}
+
+	// Download with the name of the url
+	got := run(map[string]any{"url": srv.URL + "/files/data.bin", "sha256": checksum})
+	res, ok := got.(*DownloadResult)
+	if !ok {
+		t.Fatalf("expected download result, got %v", got)
+	}
+	if res.Filename != "data.bin" || res.Size != int64(len(content)) || res.SHA256 != checksum || res.ContentType != "application/octet-stream" || res.Resumed {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	// Resume a partial download
+	if err := os.WriteFile(filepath.Join(dir, "resumed.bin.part"), content[:4000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	ranges = nil
+	got = run(map[string]any{"url": srv.URL, "filename": "resumed.bin"})
+	if res, ok := got.(*DownloadResult); !ok || !res.Resumed || res.SHA256 != checksum {
+		t.Errorf("unexpected resumed result %v", got)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=4000-" {
+		t.Errorf("unexpected ranges %v", ranges)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "resumed.bin"))
+	if err != nil || !bytes.Equal(data, content) {
+		t.Errorf("resumed file doesn't match (%v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "resumed.bin.part")); !os.IsNotExist(err) {
+		t.Errorf("partial file wasn't removed")
+	}
+
+	// Errors
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"max-size", map[string]any{"url": srv.URL, "filename": "big.bin", "max_size": 100}, "exceeds the limit"},
+		{"checksum", map[string]any{"url": srv.URL, "filename": "bad.bin", "sha256": "abc"}, "checksum mismatch"},
+		{"jail", map[string]any{"url": srv.URL, "filename": "../escape.bin"}, "outside the workspace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := errorOf(run(tt.args))
+			if !strings.Contains(msg, tt.want) {
+				t.Errorf("expected error containing %q, got %q", tt.want, msg)
+			}
+		})
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			// Data keeps arriving for longer than the idle timeout
+			w.Header().Set("Content-Length", "1000")
+			for i := 0; i < 10; i++ {
+				_, _ = w.Write(content[i*100 : (i+1)*100])
+				w.(http.Flusher).Flush()
+				time.Sleep(50 * time.Millisecond)
+			}
+			return
+		}
+		if r.URL.Path == "/stall" {
+			w.Header().Set("Content-Length", "10000")
+			_, _ = w.Write(content[:100])
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cmd := &DownloadCommand{ws: NewWorkspace(dir, nil), http: NewHTTPCommand("", nil, nil), maxSize: 20000, idle: 200 * time.Millisecond}
+	run := func(raw map[string]any) any {
+		t.Helper()
+		args, err := Validate(cmd, []any{raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmd.Run(context.Background(), args)
+	}
+	writePart := func(name string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name+".part"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A complete partial file is accepted when the range can't be satisfied
+	writePart("complete.bin", content)
+	got := run(map[string]any{"url": srv.URL, "filename": "complete.bin"})
+	if res, ok := got.(*DownloadResult); !ok || res.Size != int64(len(content)) {
+		t.Errorf("unexpected result %v", got)
+	}
+
+	// A partial file bigger than the remote file is discarded
+	writePart("bigger.bin", append(content, 'x'))
+	if msg := errorOf(run(map[string]any{"url": srv.URL, "filename": "bigger.bin"})); !strings.Contains(msg, "doesn't match the remote file") {
+		t.Errorf("unexpected result %q", msg)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bigger.bin.part")); !os.IsNotExist(err) {
+		t.Errorf("mismatched partial file wasn't removed")
+	}
+
+	// Slow downloads aren't canceled while data arrives
+	got = run(map[string]any{"url": srv.URL + "/slow", "filename": "slow.bin"})
+	if res, ok := got.(*DownloadResult); !ok || res.Size != 1000 {
+		t.Errorf("unexpected result %v", got)
+	}
+
+	// Stalled downloads are canceled and kept to be resumed
+	if msg := errorOf(run(map[string]any{"url": srv.URL + "/stall", "filename": "stall.bin"})); !strings.Contains(msg, "no data received") {
+		t.Errorf("unexpected result %q", msg)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "stall.bin.part")); err != nil || info.Size() != 100 {
+		t.Errorf("partial file not kept: %v", err)
+	}
+}
@@ -0,0 +1,592 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/igogpt/pkg/shell"
+)
+
+// maxGitOutput is the maximum number of bytes kept of the git output.
+const maxGitOutput = 1024 * 1024
+
+// gitBase runs git commands inside the workspace using the bash executor.
+type gitBase struct {
+	ws      *Workspace
+	exec    shell.Executor
+	env     []string
+	timeout time.Duration
+	proxy   string
+}
+
+// git runs git in the given directory and returns its output.
+func (g *gitBase) git(ctx context.Context, dir string, args ...string) (string, error) {
+	return g.gitEnv(ctx, dir, nil, args...)
+}
+
+// gitEnv is like git but it adds the given variables to the environment.
+func (g *gitBase) gitEnv(ctx context.Context, dir string, extra []string, args ...string) (string, error) {
+	allow := g.env
+	if len(allow) == 0 {
+		allow = shell.DefaultEnv
+	}
+	env := append(shell.FilterEnv(os.Environ(), allow), "GIT_TERMINAL_PROMPT=0", "GIT_CEILING_DIRECTORIES="+g.ceilings())
+	env = append(env, extra...)
+	cmd := []string{"git", "-c", "core.quotepath=off", "-c", "protocol.ext.allow=never", "-c", "protocol.file.allow=never", "-c", "http.followRedirects=false"}
+	if g.proxy != "" {
+		cmd = append(cmd, "-c", "http.proxy="+g.proxy)
+	}
+	result, err := g.exec.Run(ctx, &shell.Request{
+		Command:   append(cmd, args...),
+		Dir:       dir,
+		Env:       env,
+		Timeout:   g.timeout,
+		MaxOutput: maxGitOutput,
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 || result.Error != "" {
+		msg := strings.TrimSpace(result.Stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(result.Stdout)
+		}
+		if msg == "" {
+			msg = result.Error
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+	return result.Stdout, nil
+}
+
+// ceilings returns the directories where git stops searching for
+// repositories, the parents of the workspace and its mounts, so that the
+// repositories of parent directories aren't used.
+func (g *gitBase) ceilings() string {
+	var dirs []string
+	for _, dir := range g.ws.dirs() {
+		if real, err := evalSymlinks(dir); err == nil {
+			dir = real
+		}
+		dirs = append(dirs, filepath.Dir(dir))
+	}
+	return strings.Join(dirs, string(os.PathListSeparator))
+}
+
+// repo returns the absolute path of a repository directory of the workspace.
+// It fails if the top level directory of the repository is outside the
+// workspace.
+func (g *gitBase) repo(ctx context.Context, dir string, write bool) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	resolve := g.ws.ReadPath
+	if write {
+		resolve = g.ws.Path
+	}
+	abs, err := resolve(dir)
+	if err != nil {
+		return "", err
+	}
+	out, err := g.git(ctx, abs, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	if top := filepath.FromSlash(strings.TrimSpace(out)); !g.ws.contains(top) {
+		return "", fmt.Errorf("repository of %q is outside the workspace", dir)
+	}
+	return abs, nil
+}
+
+// checkRef returns an error if the value could be interpreted as an option.
+func checkRef(name, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("invalid %s %q", name, value)
+	}
+	return nil
+}
+
+// GitCloneCommand clones a repository into the output directory
+type GitCloneCommand struct {
+	*gitBase
+	http *HTTPCommand
+}
+
+func (c *GitCloneCommand) Name() string {
+	return "git_clone"
+}
+
+func (c *GitCloneCommand) Description() string {
+	return "Clone git repository"
+}
+
+func (c *GitCloneCommand) Args() []Arg {
+	return []Arg{
+		{Name: "url", Type: TypeString, Required: true, Description: "url of the repository"},
+		{Name: "directory", Type: TypeString, Description: "directory to clone into, defaults to the repository name"},
+		{Name: "branch", Type: TypeString, Description: "branch or tag to check out"},
+		{Name: "depth", Type: TypeInt, Default: 1, Description: "number of commits to fetch, 0 means the full history"},
+	}
+}
+
+func (c *GitCloneCommand) SideEffects() bool {
+	return true
+}
+
+// GitCloneResult is the result of a git_clone command.
+type GitCloneResult struct {
+	Directory string `json:"directory"`
+	Branch    string `json:"branch"`
+	Commit    string `json:"commit"`
+}
+
+func (c *GitCloneCommand) Run(ctx context.Context, args Args) any {
+	u := strings.TrimSpace(args.String("url"))
+	if err := checkRef("url", u); err != nil {
+		return logErr(err)
+	}
+	parsed, err := parseCloneURL(u)
+	if err != nil {
+		return logErr(err)
+	}
+	if err := c.http.checkDomain(parsed); err != nil {
+		return logErr(err)
+	}
+	dir := args.String("directory")
+	if dir == "" {
+		dir = strings.TrimSuffix(path.Base(strings.TrimRight(u, "/")), ".git")
+	}
+	abs, err := c.ws.Path(dir)
+	if err != nil {
+		return logErr(err)
+	}
+	if _, err := os.Stat(abs); err == nil {
+		return logErr(fmt.Errorf("directory %q already exists", dir))
+	}
+
+	cloneArgs := []string{"clone", "--quiet"}
+	if depth := args.Int("depth"); depth > 0 {
+		cloneArgs = append(cloneArgs, "--depth", strconv.Itoa(depth))
+	}
+	if branch := args.String("branch"); branch != "" {
+		if err := checkRef("branch", branch); err != nil {
+			return logErr(err)
+		}
+		cloneArgs = append(cloneArgs, "--branch", branch)
+	}
+	cloneArgs = append(cloneArgs, "--", u, abs)
+	if _, err := c.git(ctx, c.ws.Root(), cloneArgs...); err != nil {
+		return logErr(fmt.Errorf("couldn't clone repository: %w", err))
+	}
+
+	result := &GitCloneResult{Directory: c.ws.Rel(abs)}
+	if out, err := c.git(ctx, abs, "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		result.Branch = strings.TrimSpace(out)
+	}
+	if out, err := c.git(ctx, abs, "rev-parse", "--short", "HEAD"); err == nil {
+		result.Commit = strings.TrimSpace(out)
+	}
+	return result
+}
+
+// parseCloneURL parses the url of a repository to be cloned.
+// Only http, https and ssh urls are allowed, including the scp-like syntax
+// "user@host:path". Local paths and other protocols are rejected so that
+// repositories outside the workspace can't be cloned.
+func parseCloneURL(u string) (*url.URL, error) {
+	if strings.Contains(u, "::") {
+		// Remote helper syntax "<transport>::<address>"
+		return nil, fmt.Errorf("invalid url %q, only http, https and ssh urls are allowed", u)
+	}
+	if !strings.Contains(u, "://") {
+		// scp-like syntax, a single letter host is a windows drive
+		i := strings.Index(u, ":")
+		if i < 0 || strings.Contains(u[:i], "/") || len(u[strings.LastIndex(u[:i], "@")+1:i]) < 2 {
+			return nil, fmt.Errorf("invalid url %q, only http, https and ssh urls are allowed", u)
+		}
+		u = "ssh://" + u[:i] + "/" + u[i+1:]
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "ssh":
+	default:
+		return nil, fmt.Errorf("invalid url scheme %q, only http, https and ssh urls are allowed", parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("invalid url %q, missing host", u)
+	}
+	return parsed, nil
+}
+
+// GitStatusCommand returns the status of a repository
+type GitStatusCommand struct {
+	*gitBase
+}
+
+func (c *GitStatusCommand) Name() string {
+	return "git_status"
+}
+
+func (c *GitStatusCommand) Description() string {
+	return "Show git status"
+}
+
+func (c *GitStatusCommand) Args() []Arg {
+	return []Arg{
+		{Name: "directory", Type: TypeString, Default: ".", Description: "directory of the repository"},
+	}
+}
+
+// GitStatus is the result of a git_status command.
+// Changed files are in the format "<change>: <path>".
+type GitStatus struct {
+	Branch    string   `json:"branch"`
+	Upstream  string   `json:"upstream,omitempty"`
+	Ahead     int      `json:"ahead,omitempty"`
+	Behind    int      `json:"behind,omitempty"`
+	Staged    []string `json:"staged,omitempty"`
+	Unstaged  []string `json:"unstaged,omitempty"`
+	Untracked []string `json:"untracked,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Clean     bool     `json:"clean"`
+}
+
+func (c *GitStatusCommand) Run(ctx context.Context, args Args) any {
+	dir, err := c.repo(ctx, args.String("directory"), false)
+	if err != nil {
+		return logErr(err)
+	}
+	out, err := c.git(ctx, dir, "status", "--porcelain=v1", "--branch")
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't get status: %w", err))
+	}
+	return parseGitStatus(out)
+}
+
+func parseGitStatus(out string) *GitStatus {
+	status := &GitStatus{}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			parseGitBranch(status, line[3:])
+			continue
+		}
+		if len(line) < 4 {
+			continue
+		}
+		x, y, file := line[0], line[1], line[3:]
+		switch {
+		case x == '?' && y == '?':
+			status.Untracked = append(status.Untracked, file)
+		case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+			status.Conflicts = append(status.Conflicts, file)
+		default:
+			if x != ' ' {
+				status.Staged = append(status.Staged, gitChange(x)+": "+file)
+			}
+			if y != ' ' {
+				status.Unstaged = append(status.Unstaged, gitChange(y)+": "+file)
+			}
+		}
+	}
+	status.Clean = len(status.Staged)+len(status.Unstaged)+len(status.Untracked)+len(status.Conflicts) == 0
+	return status
+}
+
+// parseGitBranch parses the branch line, e.g. "main...origin/main [ahead 1]".
+func parseGitBranch(status *GitStatus, line string) {
+	if i := strings.Index(line, " ["); i >= 0 {
+		for _, part := range strings.Split(strings.Trim(line[i+2:], "]"), ", ") {
+			fields := strings.Fields(part)
+			if len(fields) != 2 {
+				continue
+			}
+			n, _ := strconv.Atoi(fields[1])
+			switch fields[0] {
+			case "ahead":
+				status.Ahead = n
+			case "behind":
+				status.Behind = n
+			}
+		}
+		line = line[:i]
+	}
+	line = strings.TrimPrefix(line, "No commits yet on ")
+	status.Branch = line
+	if i := strings.Index(line, "..."); i >= 0 {
+		status.Branch, status.Upstream = line[:i], line[i+3:]
+	}
+}
+
+func gitChange(c byte) string {
+	switch c {
+	case 'M':
+		return "modified"
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	case 'T':
+		return "type changed"
+	}
+	return string(c)
+}
+
+// GitDiffCommand returns the changes of a repository
+type GitDiffCommand struct {
+	*gitBase
+}
+
+func (c *GitDiffCommand) Name() string {
+	return "git_diff"
+}
+
+func (c *GitDiffCommand) Description() string {
+	return "Show git diff"
+}
+
+func (c *GitDiffCommand) Args() []Arg {
+	return []Arg{
+		{Name: "directory", Type: TypeString, Default: ".", Description: "directory of the repository"},
+		{Name: "staged", Type: TypeBool, Default: false, Description: "show staged changes instead of unstaged ones"},
+		{Name: "revision", Type: TypeString, Description: "commit or range to compare, e.g. HEAD~1 or main..feature"},
+		{Name: "path", Type: TypeString, Description: "limit the diff to this path"},
+	}
+}
+
+// GitDiff is the result of a git_diff command.
+type GitDiff struct {
+	Files   []GitFileStat `json:"files"`
+	Added   int           `json:"added"`
+	Removed int           `json:"removed"`
+	Diff    string        `json:"diff,omitempty"`
+}
+
+// GitFileStat has the number of lines changed in a file, binary files don't
+// have line counts.
+type GitFileStat struct {
+	Path    string `json:"path"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Binary  bool   `json:"binary,omitempty"`
+}
+
+func (c *GitDiffCommand) Run(ctx context.Context, args Args) any {
+	dir, err := c.repo(ctx, args.String("directory"), false)
+	if err != nil {
+		return logErr(err)
+	}
+	diffArgs := []string{"diff", "--no-color", "--no-ext-diff"}
+	if args.Bool("staged") {
+		diffArgs = append(diffArgs, "--cached")
+	}
+	if rev := args.String("revision"); rev != "" {
+		if err := checkRef("revision", rev); err != nil {
+			return logErr(err)
+		}
+		diffArgs = append(diffArgs, rev)
+	}
+	var paths []string
+	if p := args.String("path"); p != "" {
+		paths = []string{"--", p}
+	}
+
+	numstat, err := c.git(ctx, dir, append(append(append([]string{}, diffArgs...), "--numstat"), paths...)...)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't get diff: %w", err))
+	}
+	result := &GitDiff{Files: []GitFileStat{}}
+	for _, line := range strings.Split(numstat, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		stat := GitFileStat{Path: fields[2]}
+		if fields[0] == "-" {
+			stat.Binary = true
+		} else {
+			stat.Added, _ = strconv.Atoi(fields[0])
+			stat.Removed, _ = strconv.Atoi(fields[1])
+		}
+		result.Added += stat.Added
+		result.Removed += stat.Removed
+		result.Files = append(result.Files, stat)
+	}
+
+	// The diff is truncated with the rest of the results to fit the budget
+	diff, err := c.git(ctx, dir, append(diffArgs, paths...)...)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't get diff: %w", err))
+	}
+	result.Diff = diff
+	return result
+}
+
+// GitCommitCommand commits the changes of a repository
+type GitCommitCommand struct {
+	*gitBase
+}
+
+func (c *GitCommitCommand) Name() string {
+	return "git_commit"
+}
+
+func (c *GitCommitCommand) Description() string {
+	return "Commit git changes"
+}
+
+func (c *GitCommitCommand) Args() []Arg {
+	return []Arg{
+		{Name: "message", Type: TypeString, Required: true, Description: "commit message"},
+		{Name: "directory", Type: TypeString, Default: ".", Description: "directory of the repository"},
+		{Name: "all", Type: TypeBool, Default: true, Description: "stage all changes, including untracked files, before committing"},
+	}
+}
+
+func (c *GitCommitCommand) SideEffects() bool {
+	return true
+}
+
+// GitCommitResult is the result of a git_commit command.
+type GitCommitResult struct {
+	Commit  string `json:"commit"`
+	Summary string `json:"summary"`
+	Files   int    `json:"files"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+func (c *GitCommitCommand) Run(ctx context.Context, args Args) any {
+	dir, err := c.repo(ctx, args.String("directory"), true)
+	if err != nil {
+		return logErr(err)
+	}
+	if args.Bool("all") {
+		if _, err := c.git(ctx, dir, "add", "--all"); err != nil {
+			return logErr(fmt.Errorf("couldn't stage changes: %w", err))
+		}
+	}
+	if _, err := c.git(ctx, dir, "diff", "--cached", "--quiet"); err == nil {
+		return logErr(fmt.Errorf("nothing to commit"))
+	}
+
+	// Use a default identity if none is configured
+	var env []string
+	if out, _ := c.git(ctx, dir, "config", "user.email"); strings.TrimSpace(out) == "" {
+		env = []string{
+			"GIT_AUTHOR_NAME=igogpt", "GIT_AUTHOR_EMAIL=igogpt@localhost",
+			"GIT_COMMITTER_NAME=igogpt", "GIT_COMMITTER_EMAIL=igogpt@localhost",
+		}
+	}
+	if _, err := c.gitEnv(ctx, dir, env, "commit", "--quiet", "--no-verify", "-m", args.String("message")); err != nil {
+		return logErr(fmt.Errorf("couldn't commit: %w", err))
+	}
+
+	out, err := c.git(ctx, dir, "show", "--numstat", "--format=%h%x1f%s", "HEAD")
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't get commit: %w", err))
+	}
+	result := &GitCommitResult{}
+	for i, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if i == 0 {
+			parts := strings.SplitN(line, "\x1f", 2)
+			result.Commit = parts[0]
+			if len(parts) > 1 {
+				result.Summary = parts[1]
+			}
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		added, _ := strconv.Atoi(fields[0])
+		removed, _ := strconv.Atoi(fields[1])
+		result.Files++
+		result.Added += added
+		result.Removed += removed
+	}
+	return result
+}
+
+// GitLogCommand returns the latest commits of a repository
+type GitLogCommand struct {
+	*gitBase
+}
+
+func (c *GitLogCommand) Name() string {
+	return "git_log"
+}
+
+func (c *GitLogCommand) Description() string {
+	return "Show git log"
+}
+
+func (c *GitLogCommand) Args() []Arg {
+	return []Arg{
+		{Name: "directory", Type: TypeString, Default: ".", Description: "directory of the repository"},
+		{Name: "max", Type: TypeInt, Default: 10, Description: "max number of commits"},
+		{Name: "revision", Type: TypeString, Description: "branch, commit or range to show"},
+		{Name: "path", Type: TypeString, Description: "only show commits changing this path"},
+	}
+}
+
+// GitLogEntry is a commit returned by the git_log command.
+type GitLogEntry struct {
+	Commit  string `json:"commit"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
+}
+
+func (c *GitLogCommand) Run(ctx context.Context, args Args) any {
+	dir, err := c.repo(ctx, args.String("directory"), false)
+	if err != nil {
+		return logErr(err)
+	}
+	limit := args.Int("max")
+	if limit <= 0 {
+		limit = 10
+	}
+	logArgs := []string{"log", "--no-color", "-n", strconv.Itoa(limit), "--format=%h%x1f%an%x1f%aI%x1f%s"}
+	if rev := args.String("revision"); rev != "" {
+		if err := checkRef("revision", rev); err != nil {
+			return logErr(err)
+		}
+		logArgs = append(logArgs, rev)
+	}
+	if p := args.String("path"); p != "" {
+		logArgs = append(logArgs, "--", p)
+	}
+	out, err := c.git(ctx, dir, logArgs...)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't get log: %w", err))
+	}
+	entries := []GitLogEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, "\x1f", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		entries = append(entries, GitLogEntry{
+			Commit:  parts[0],
+			Author:  parts[1],
+			Date:    parts[2],
+			Subject: parts[3],
+		})
+	}
+	return entries
+}
@@ -0,0 +1,211 @@
+package command
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	r := New(&Config{Output: dir})
+	cmds := map[string]Command{}
+	for _, c := range r.Commands() {
+		cmds[c.Name()] = c
+	}
+	run := func(name string, raw map[string]any) any {
+		t.Helper()
+		args, err := Validate(cmds[name], []any{raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmds[name].Run(ctx, args)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "repo", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create a repository
+	repo := filepath.Join(dir, "repo")
+	if out, err := exec.Command("git", "init", "--quiet", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	write("a.txt", "one\ntwo\n")
+
+	status, ok := run("git_status", map[string]any{"directory": "repo"}).(*GitStatus)
+	if !ok || !reflect.DeepEqual(status.Untracked, []string{"a.txt"}) || status.Clean {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	commit, ok := run("git_commit", map[string]any{"directory": "repo", "message": "Add a"}).(*GitCommitResult)
+	if !ok || commit.Summary != "Add a" || commit.Files != 1 || commit.Added != 2 {
+		t.Fatalf("unexpected commit %+v", commit)
+	}
+	if msg := errorOf(run("git_commit", map[string]any{"directory": "repo", "message": "Empty"})); !strings.Contains(msg, "nothing to commit") {
+		t.Errorf("expected nothing to commit, got %q", msg)
+	}
+
+	write("a.txt", "one\n2\nthree\n")
+	diff, ok := run("git_diff", map[string]any{"directory": "repo"}).(*GitDiff)
+	if !ok || len(diff.Files) != 1 || diff.Added != 2 || diff.Removed != 1 || !strings.Contains(diff.Diff, "+three") {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	status, ok = run("git_status", map[string]any{"directory": "repo"}).(*GitStatus)
+	if !ok || !reflect.DeepEqual(status.Unstaged, []string{"modified: a.txt"}) {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	run("git_commit", map[string]any{"directory": "repo", "message": "Update a"})
+
+	log, ok := run("git_log", map[string]any{"directory": "repo"}).([]GitLogEntry)
+	if !ok || len(log) != 2 || log[0].Subject != "Update a" || log[1].Subject != "Add a" {
+		t.Fatalf("unexpected log %+v", log)
+	}
+
+	// Serve the repository with the dumb http protocol
+	if out, err := exec.Command("git", "-C", repo, "update-server-info").CombinedOutput(); err != nil {
+		t.Fatalf("git update-server-info: %v: %s", err, out)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(filepath.Join(repo, ".git"))))
+	defer srv.Close()
+	clone, ok := run("git_clone", map[string]any{"url": srv.URL + "/", "directory": "copy", "depth": 0}).(*GitCloneResult)
+	if !ok || clone.Directory != "copy" || clone.Commit != log[0].Commit {
+		t.Fatalf("unexpected clone %+v", clone)
+	}
+
+	// Only remote repositories can be cloned
+	for _, u := range []string{repo, "file://" + repo, "ext::sh -c touch% /tmp/pwned", "C:/repo"} {
+		if msg := errorOf(run("git_clone", map[string]any{"url": u, "directory": "local"})); !strings.Contains(msg, "only http, https and ssh") {
+			t.Errorf("%s: expected url error, got %q", u, msg)
+		}
+	}
+
+	// Options and paths outside the workspace are rejected
+	if msg := errorOf(run("git_log", map[string]any{"directory": "repo", "revision": "--output=x"})); !strings.Contains(msg, "invalid revision") {
+		t.Errorf("expected invalid revision, got %q", msg)
+	}
+	if msg := errorOf(run("git_status", map[string]any{"directory": ".."})); !strings.Contains(msg, "outside the workspace") {
+		t.Errorf("expected path error, got %q", msg)
+	}
+}
+
+func TestParseCloneURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{url: "https://github.com/igolaizola/igogpt.git", want: "https://github.com/igolaizola/igogpt.git"},
+		{url: "ssh://git@github.com/igolaizola/igogpt.git", want: "ssh://git@github.com/igolaizola/igogpt.git"},
+		{url: "git@github.com:igolaizola/igogpt.git", want: "ssh://git@github.com/igolaizola/igogpt.git"},
+		{url: "/home/user/repo", wantErr: true},
+		{url: "../repo", wantErr: true},
+		{url: "file:///home/user/repo", wantErr: true},
+		{url: "git://github.com/igolaizola/igogpt.git", wantErr: true},
+		{url: "C:/repo", wantErr: true},
+		{url: "ext::ssh -oProxyCommand=x host", wantErr: true},
+		{url: "https:///repo", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCloneURL(tt.url)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got %s", tt.url, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseGitStatus(t *testing.T) {
+	out := "## main...origin/main [ahead 2, behind 1]\nM  staged.go\n M changed.go\nMM both.go\nUU conflict.go\n?? new.go\n"
+	want := &GitStatus{
+		Branch:    "main",
+		Upstream:  "origin/main",
+		Ahead:     2,
+		Behind:    1,
+		Staged:    []string{"modified: staged.go", "modified: both.go"},
+		Unstaged:  []string{"modified: changed.go", "modified: both.go"},
+		Untracked: []string{"new.go"},
+		Conflicts: []string{"conflict.go"},
+	}
+	if got := parseGitStatus(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGitStatus() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGitParentRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	ctx := context.Background()
+
+	// The output directory is inside a repository with untracked files
+	parent := t.TempDir()
+	if out, err := exec.Command("git", "init", "--quiet", parent).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(parent, "output")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r := New(&Config{Output: dir})
+	cmds := map[string]Command{}
+	for _, c := range r.Commands() {
+		cmds[c.Name()] = c
+	}
+	run := func(name string, raw map[string]any) any {
+		t.Helper()
+		args, err := Validate(cmds[name], []any{raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmds[name].Run(ctx, args)
+	}
+
+	for _, name := range []string{"git_status", "git_commit", "git_diff", "git_log"} {
+		for _, d := range []string{".", "sub"} {
+			raw := map[string]any{"directory": d}
+			if name == "git_commit" {
+				raw["message"] = "steal"
+			}
+			got := run(name, raw)
+			if msg := errorOf(got); !strings.Contains(msg, "not a git repository") {
+				t.Errorf("%s %s: expected error, got %v", name, d, got)
+			}
+		}
+	}
+	if out, err := exec.Command("git", "-C", parent, "log", "--oneline").CombinedOutput(); err == nil {
+		t.Errorf("commit created in the parent repository: %s", out)
+	}
+
+	// Repositories inside the output directory can be used
+	if out, err := exec.Command("git", "init", "--quiet", filepath.Join(dir, "sub")).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	if status, ok := run("git_status", map[string]any{"directory": "sub"}).(*GitStatus); !ok || !status.Clean {
+		t.Errorf("unexpected status %v", status)
+	}
+}
@@ -0,0 +1,295 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	inthttp "github.com/igolaizola/igogpt/internal/http"
+)
+
+// maxHTTPBody is the maximum size of the response body read.
+const maxHTTPBody = 1024 * 1024
+
+// httpHeaders are the response headers always returned.
+var httpHeaders = []string{
+	"Content-Type", "Content-Length", "Location", "Retry-After", "Link",
+	"ETag", "Last-Modified", "WWW-Authenticate",
+}
+
+// HTTPCommand sends HTTP requests to call APIs.
+type HTTPCommand struct {
+	client *http.Client
+	allow  []string
+	deny   []string
+}
+
+// NewHTTPCommand creates an http command that sends requests through the given
+// proxy, if not empty.
+// Requests to domains in the deny list are rejected and, if the allow list
+// isn't empty, only its domains can be requested. Subdomains are included.
+func NewHTTPCommand(proxy string, allow, deny []string) *HTTPCommand {
+	c := &HTTPCommand{
+		allow: allow,
+		deny:  deny,
+	}
+	client, err := inthttp.NewGoClient("", "", "", proxy)
+	if err != nil {
+		// Fail the requests instead of skipping the proxy
+		err = fmt.Errorf("invalid proxy %q: %w", proxy, err)
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.Proxy = func(*http.Request) (*url.URL, error) { return nil, err }
+		client = &http.Client{Transport: tr}
+	} else if tr, ok := client.Transport.(*http.Transport); ok {
+		// The proxy is set by the dialer, ignore the environment
+		tr.Proxy = nil
+	}
+	c.client = &http.Client{
+		Transport: client.Transport,
+		Timeout:   30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return c.checkDomain(req.URL)
+		},
+	}
+	return c
+}
+
+func (c *HTTPCommand) Name() string {
+	return "http"
+}
+
+func (c *HTTPCommand) Description() string {
+	return "Send HTTP request to an API"
+}
+
+func (c *HTTPCommand) Args() []Arg {
+	return []Arg{
+		{Name: "url", Type: TypeString, Required: true, Description: "url of the request"},
+		{Name: "method", Type: TypeString, Default: "GET", Description: "http method"},
+		{Name: "headers", Type: TypeString, Description: `request headers as a JSON object, e.g. {"Accept": "application/json"}`},
+		{Name: "query", Type: TypeString, Description: "query parameters as a JSON object or url encoded"},
+		{Name: "json", Type: TypeString, Description: "JSON body"},
+		{Name: "form", Type: TypeString, Description: "form body as a JSON object or url encoded"},
+		{Name: "response_headers", Type: TypeString, Description: "comma separated response headers to return besides the common ones"},
+	}
+}
+
+func (c *HTTPCommand) SideEffects() bool {
+	return true
+}
+
+// Mutates returns false for the methods that only read
+func (c *HTTPCommand) Mutates(args Args) bool {
+	switch strings.ToUpper(strings.TrimSpace(args.String("method"))) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// HTTPResult is the result of an http command.
+type HTTPResult struct {
+	Status    int               `json:"status"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+func (c *HTTPCommand) Run(ctx context.Context, args Args) any {
+	u := args.String("url")
+	if !strings.Contains(u, "://") {
+		u = "https://" + u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return logErr(fmt.Errorf("invalid url: %w", err))
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return logErr(fmt.Errorf("invalid url scheme %q", parsed.Scheme))
+	}
+	if err := c.checkDomain(parsed); err != nil {
+		return logErr(err)
+	}
+
+	// Add query parameters
+	if q := args.String("query"); q != "" {
+		values, err := toValues(q)
+		if err != nil {
+			return logErr(fmt.Errorf("invalid query: %w", err))
+		}
+		query := parsed.Query()
+		for k, vs := range values {
+			for _, v := range vs {
+				query.Add(k, v)
+			}
+		}
+		parsed.RawQuery = query.Encode()
+	}
+
+	// Prepare body
+	var body io.Reader
+	var contentType string
+	switch {
+	case args.String("json") != "" && args.String("form") != "":
+		return logErr(fmt.Errorf("json and form can't be used together"))
+	case args.String("json") != "":
+		js := args.String("json")
+		if !json.Valid([]byte(js)) {
+			return logErr(fmt.Errorf("invalid json body"))
+		}
+		body = strings.NewReader(js)
+		contentType = "application/json"
+	case args.String("form") != "":
+		values, err := toValues(args.String("form"))
+		if err != nil {
+			return logErr(fmt.Errorf("invalid form: %w", err))
+		}
+		body = strings.NewReader(values.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(args.String("method")))
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, parsed.String(), body)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't create request: %w", err))
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if h := args.String("headers"); h != "" {
+		headers, err := toHeaders(h)
+		if err != nil {
+			return logErr(fmt.Errorf("invalid headers: %w", err))
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't send request: %w", err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody+1))
+	if err != nil {
+		return logErr(fmt.Errorf("couldn't read response: %w", err))
+	}
+
+	result := &HTTPResult{
+		Status:  resp.StatusCode,
+		Headers: map[string]string{},
+	}
+	if len(data) > maxHTTPBody {
+		data = data[:maxHTTPBody]
+		result.Truncated = true
+	}
+	names := append(append([]string{}, httpHeaders...), splitGlobs(args.String("response_headers"))...)
+	for _, name := range names {
+		if v := resp.Header.Values(name); len(v) > 0 {
+			result.Headers[http.CanonicalHeaderKey(name)] = strings.Join(v, ", ")
+		}
+	}
+
+	// Pretty print JSON responses
+	var pretty bytes.Buffer
+	if !result.Truncated && json.Indent(&pretty, data, "", "  ") == nil {
+		data = pretty.Bytes()
+	}
+	if !utf8.Valid(data) {
+		result.Body = fmt.Sprintf("binary content of %d bytes not shown", len(data))
+	} else {
+		result.Body = string(data)
+	}
+	return result
+}
+
+// checkDomain returns an error if the domain of the url isn't allowed.
+func (c *HTTPCommand) checkDomain(u *url.URL) error {
+	host := strings.ToLower(u.Hostname())
+	if matchDomain(c.deny, host) {
+		return fmt.Errorf("domain %q is denied", host)
+	}
+	if len(c.allow) > 0 && !matchDomain(c.allow, host) {
+		return fmt.Errorf("domain %q not allowed, allowed domains: %s", host, strings.Join(c.allow, ", "))
+	}
+	return nil
+}
+
+// toValues parses a JSON object or a url encoded string into url values.
+func toValues(s string) (url.Values, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return url.ParseQuery(strings.TrimPrefix(s, "?"))
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	for k, v := range m {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				values.Add(k, toString(e))
+			}
+		default:
+			values.Add(k, toString(v))
+		}
+	}
+	return values, nil
+}
+
+// toHeaders parses a JSON object or "Key: Value" lines into headers.
+func toHeaders(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	headers := map[string]string{}
+	if strings.HasPrefix(s, "{") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			headers[k] = toString(v)
+		}
+		return headers, nil
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("expected \"Key: Value\", got %q", line)
+		}
+		headers[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+	return headers, nil
+}
+
+func toString(v any) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	}
+	s, err := coerce(TypeString, v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return s.(string)
+}
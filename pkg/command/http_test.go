@@ -0,0 +1,93 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "42")
+		w.Header().Set("X-Ignored", "1")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"method":       r.Method,
+			"query":        r.URL.RawQuery,
+			"auth":         r.Header.Get("Authorization"),
+			"content_type": r.Header.Get("Content-Type"),
+			"body":         string(body),
+		})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	cmd := NewHTTPCommand("", []string{"127.0.0.1"}, nil)
+	run := func(raw map[string]any) any {
+		t.Helper()
+		args, err := Validate(cmd, []any{raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmd.Run(ctx, args)
+	}
+
+	// JSON body with query and headers
+	got := run(map[string]any{
+		"url":              srv.URL + "/items?a=1",
+		"method":           "post",
+		"query":            map[string]any{"b": 2.0},
+		"headers":          map[string]any{"Authorization": "Bearer token"},
+		"json":             map[string]any{"name": "item"},
+		"response_headers": "X-Request-Id",
+	})
+	res, ok := got.(*HTTPResult)
+	if !ok {
+		t.Fatalf("expected http result, got %v", got)
+	}
+	if res.Status != http.StatusCreated {
+		t.Errorf("status = %d, want %d", res.Status, http.StatusCreated)
+	}
+	if res.Headers["Content-Type"] != "application/json" || res.Headers["X-Request-Id"] != "42" {
+		t.Errorf("unexpected headers %v", res.Headers)
+	}
+	if _, ok := res.Headers["X-Ignored"]; ok {
+		t.Errorf("unexpected header X-Ignored")
+	}
+	for _, s := range []string{
+		`"method": "POST"`,
+		`"query": "a=1\u0026b=2"`,
+		`"auth": "Bearer token"`,
+		`"content_type": "application/json"`,
+		`"body": "{\"name\":\"item\"}"`,
+	} {
+		if !strings.Contains(res.Body, s) {
+			t.Errorf("body doesn't contain %s:\n%s", s, res.Body)
+		}
+	}
+
+	// Form body
+	got = run(map[string]any{"url": srv.URL, "method": "PUT", "form": "x=1&y=2"})
+	if res, ok := got.(*HTTPResult); !ok || !strings.Contains(res.Body, `"body": "x=1\u0026y=2"`) {
+		t.Errorf("unexpected form result %v", got)
+	}
+
+	// Domains not allowed
+	if msg := errorOf(run(map[string]any{"url": "https://example.com"})); !strings.Contains(msg, "not allowed") {
+		t.Errorf("expected error for domain not allowed")
+	}
+	deny := NewHTTPCommand("", nil, []string{"127.0.0.1"})
+	args, err := Validate(deny, []any{srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := errorOf(deny.Run(ctx, args)); !strings.Contains(msg, "denied") {
+		t.Errorf("expected error for denied domain")
+	}
+}
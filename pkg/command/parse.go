@@ -0,0 +1,620 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CommandRequest represents a single command request
+type CommandRequest struct {
+	// Command name
+	Name string `json:"name"`
+	// Command arguments
+	Args []any `json:"args"`
+}
+
+// ParseError is returned when the commands can't be parsed.
+type ParseError struct {
+	// Line and column where the error was found, starting at 1
+	Line   int
+	Column int
+	// Fragment of the text around the error
+	Fragment string
+	// Excerpt has the lines around the error with a marker under the column
+	Excerpt string
+	Msg     string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("invalid json at line %d, column %d: %s (near %q)", e.Line, e.Column, e.Msg, e.Fragment)
+}
+
+// Thoughts are the thoughts of the AI sent along with the commands.
+type Thoughts struct {
+	Text      string `json:"text,omitempty"`
+	Reasoning string `json:"reasoning,omitempty"`
+	Plan      string `json:"plan,omitempty"`
+	Criticism string `json:"criticism,omitempty"`
+	Speak     string `json:"speak,omitempty"`
+}
+
+// Step is a parsed response of the AI.
+type Step struct {
+	Thoughts Thoughts
+	Commands []CommandRequest
+}
+
+// Parse tries to parse the commands from the given text.
+//
+// The text may contain any number of JSON blocks, surrounded by prose or code
+// fences. Each block can be the response envelope with a "commands" list, a
+// list of commands or a single command object.
+// Common mistakes such as trailing commas, single quotes, comments, unquoted
+// keys and unescaped newlines or quotes inside strings are tolerated.
+// Blocks that can't be parsed are skipped as a whole, and if one isn't closed
+// the response is considered truncated and an error is returned unless
+// previous blocks had commands.
+func Parse(text string) ([]CommandRequest, error) {
+	step, err := ParseStep(text)
+	if err != nil {
+		return nil, err
+	}
+	return step.Commands, nil
+}
+
+// ParseStep is like Parse but it also returns the thoughts of the AI.
+// The arguments of the talk and think commands are used as thoughts if the
+// response doesn't include them.
+func ParseStep(text string) (*Step, error) {
+	step := &Step{Commands: []CommandRequest{}}
+	var firstErr *ParseError
+	var found bool
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+		p := &jsonParser{text: text, pos: i}
+		v, err := p.value()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			// Skip the broken block, the blocks inside it would be parsed
+			// as commands out of their context
+			end := blockEnd(text, i)
+			if end < 0 {
+				// The block isn't closed, the response is truncated
+				break
+			}
+			i = end
+			continue
+		}
+		found = true
+		if obj, ok := v.(*object); ok {
+			if t, ok := obj.get("thoughts"); ok {
+				step.Thoughts = toThoughts(t)
+			}
+		}
+		step.Commands = append(step.Commands, toCommands(v)...)
+		i = p.pos - 1
+	}
+	if len(step.Commands) > 0 {
+		for _, c := range step.Commands {
+			switch c.Name {
+			case "think":
+				step.Thoughts.Text = orArgs(step.Thoughts.Text, c.Args)
+			case "talk":
+				step.Thoughts.Speak = orArgs(step.Thoughts.Speak, c.Args)
+			}
+		}
+		return step, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if found {
+		return nil, &ParseError{Msg: "no commands found in json"}
+	}
+	return nil, &ParseError{Msg: "no json found"}
+}
+
+// blockEnd returns the position of the bracket that closes the block starting
+// at the given position, or -1 if it isn't closed.
+// Brackets inside double quoted strings are ignored.
+func blockEnd(text string, start int) int {
+	depth := 0
+	inString := false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// toThoughts converts a parsed JSON value into thoughts.
+func toThoughts(v any) Thoughts {
+	obj, ok := v.(*object)
+	if !ok {
+		return Thoughts{Text: toText(v)}
+	}
+	var t Thoughts
+	for _, k := range obj.keys {
+		text := toText(obj.vals[k])
+		switch strings.ToLower(k) {
+		case "text", "thought", "thoughts":
+			t.Text = text
+		case "reasoning":
+			t.Reasoning = text
+		case "plan":
+			t.Plan = text
+		case "criticism":
+			t.Criticism = text
+		case "speak":
+			t.Speak = text
+		}
+	}
+	return t
+}
+
+// toText converts a parsed JSON value into text.
+// Lists are converted into bulleted lists.
+func toText(v any) string {
+	switch vv := plain(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(vv)
+	case []any:
+		var lines []string
+		for _, e := range vv {
+			line := toText(e)
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "-") {
+				line = "- " + line
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+	default:
+		js, err := json.Marshal(vv)
+		if err != nil {
+			return fmt.Sprint(vv)
+		}
+		return string(js)
+	}
+}
+
+// orArgs returns the text if it isn't empty, otherwise the args joined.
+func orArgs(text string, args []any) string {
+	if text != "" {
+		return text
+	}
+	var parts []string
+	for _, a := range args {
+		if s := toText(a); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// toCommands converts a parsed JSON value into command requests.
+func toCommands(v any) []CommandRequest {
+	var cmds []CommandRequest
+	switch vv := v.(type) {
+	case []any:
+		for _, e := range vv {
+			cmds = append(cmds, toCommands(e)...)
+		}
+	case *object:
+		// Response envelope
+		if c, ok := vv.get("commands"); ok {
+			return toCommands(c)
+		}
+		if c, ok := vv.get("command"); ok {
+			return toCommands(c)
+		}
+		// Command with name and args fields
+		if name, ok := vv.vals["name"].(string); ok && len(vv.keys) <= 2 {
+			if args, ok := vv.get("args"); ok || len(vv.keys) == 1 {
+				return []CommandRequest{{Name: name, Args: toArgs(args)}}
+			}
+		}
+		// Commands indexed by name
+		for _, k := range vv.keys {
+			if k == "thoughts" {
+				continue
+			}
+			cmds = append(cmds, CommandRequest{
+				Name: k,
+				Args: toArgs(vv.vals[k]),
+			})
+		}
+	}
+	return cmds
+}
+
+func toArgs(v any) []any {
+	switch vv := plain(v).(type) {
+	case nil:
+		return []any{}
+	case []any:
+		return vv
+	default:
+		return []any{vv}
+	}
+}
+
+// object is a JSON object that keeps the order of its keys.
+type object struct {
+	keys []string
+	vals map[string]any
+}
+
+func (o *object) get(k string) (any, bool) {
+	v, ok := o.vals[k]
+	return v, ok
+}
+
+// plain converts objects into maps.
+func plain(v any) any {
+	switch vv := v.(type) {
+	case *object:
+		m := map[string]any{}
+		for k, e := range vv.vals {
+			m[k] = plain(e)
+		}
+		return m
+	case []any:
+		for i, e := range vv {
+			vv[i] = plain(e)
+		}
+		return vv
+	}
+	return v
+}
+
+// jsonParser is a lenient JSON parser.
+type jsonParser struct {
+	text string
+	pos  int
+}
+
+func (p *jsonParser) errorf(format string, a ...any) *ParseError {
+	pos := p.pos
+	if pos > len(p.text) {
+		pos = len(p.text)
+	}
+	line := strings.Count(p.text[:pos], "\n") + 1
+	col := utf8.RuneCountInString(p.text[strings.LastIndex(p.text[:pos], "\n")+1:pos]) + 1
+	from, to := pos-20, pos+20
+	if from < 0 {
+		from = 0
+	}
+	if to > len(p.text) {
+		to = len(p.text)
+	}
+	return &ParseError{
+		Line:     line,
+		Column:   col,
+		Fragment: strings.ToValidUTF8(p.text[from:to], ""),
+		Excerpt:  excerpt(p.text, line, col),
+		Msg:      fmt.Sprintf(format, a...),
+	}
+}
+
+// excerpt returns the line of the error and the previous one, followed by a
+// line with a marker under the column of the error.
+func excerpt(text string, line, col int) string {
+	lines := strings.Split(text, "\n")
+	from := line - 2
+	if from < 0 {
+		from = 0
+	}
+	var sb strings.Builder
+	for i := from; i < line && i < len(lines); i++ {
+		l := strings.ToValidUTF8(strings.TrimRight(lines[i], "\r"), "")
+		fmt.Fprintf(&sb, "%4d | %s\n", i+1, l)
+	}
+	fmt.Fprintf(&sb, "     | %s^", strings.Repeat(" ", col-1))
+	return sb.String()
+}
+
+// skip skips whitespace and comments.
+func (p *jsonParser) skip() {
+	for p.pos < len(p.text) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(p.text[p.pos])):
+			p.pos++
+		case strings.HasPrefix(p.text[p.pos:], "//"):
+			end := strings.Index(p.text[p.pos:], "\n")
+			if end < 0 {
+				p.pos = len(p.text)
+				return
+			}
+			p.pos += end
+		case strings.HasPrefix(p.text[p.pos:], "/*"):
+			end := strings.Index(p.text[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.text)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) value() (any, *ParseError) {
+	p.skip()
+	if p.pos >= len(p.text) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch c := p.text[p.pos]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"' || c == '\'':
+		return p.string()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	default:
+		word := p.word()
+		switch word {
+		case "true", "True":
+			return true, nil
+		case "false", "False":
+			return false, nil
+		case "null", "None":
+			return nil, nil
+		}
+		if word == "" {
+			return nil, p.errorf("unexpected character %q", c)
+		}
+		p.pos -= len(word)
+		return nil, p.errorf("unexpected word %q", word)
+	}
+}
+
+func (p *jsonParser) object() (any, *ParseError) {
+	p.pos++
+	obj := &object{vals: map[string]any{}}
+	for {
+		p.skip()
+		if p.pos >= len(p.text) {
+			return nil, p.errorf("unexpected end of input, expected '}'")
+		}
+		if p.text[p.pos] == '}' {
+			p.pos++
+			return obj, nil
+		}
+
+		// Parse key, quotes are optional
+		var key string
+		if c := p.text[p.pos]; c == '"' || c == '\'' {
+			k, err := p.string()
+			if err != nil {
+				return nil, err
+			}
+			key = k.(string)
+		} else {
+			key = p.word()
+			if key == "" {
+				return nil, p.errorf("expected object key")
+			}
+		}
+		p.skip()
+		if p.pos >= len(p.text) || p.text[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after key %q", key)
+		}
+		p.pos++
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := obj.vals[key]; !ok {
+			obj.keys = append(obj.keys, key)
+		}
+		obj.vals[key] = v
+
+		p.skip()
+		if p.pos >= len(p.text) {
+			return nil, p.errorf("unexpected end of input, expected '}'")
+		}
+		switch p.text[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return nil, p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+func (p *jsonParser) array() (any, *ParseError) {
+	p.pos++
+	arr := []any{}
+	for {
+		p.skip()
+		if p.pos >= len(p.text) {
+			return nil, p.errorf("unexpected end of input, expected ']'")
+		}
+		if p.text[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+
+		p.skip()
+		if p.pos >= len(p.text) {
+			return nil, p.errorf("unexpected end of input, expected ']'")
+		}
+		switch p.text[p.pos] {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+// string parses a string quoted with double or single quotes.
+// Quotes that aren't followed by a delimiter are considered part of the
+// string and raw newlines are kept.
+func (p *jsonParser) string() (any, *ParseError) {
+	quote := p.text[p.pos]
+	start := p.pos
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			if p.closes() {
+				return sb.String(), nil
+			}
+			sb.WriteByte(c)
+		case c == '\\' && p.pos+1 < len(p.text):
+			p.pos++
+			e := p.text[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case '"', '\'', '\\', '/':
+				sb.WriteByte(e)
+			case 'u':
+				r, ok := p.unicode()
+				if !ok {
+					sb.WriteString(`\u`)
+					continue
+				}
+				sb.WriteRune(r)
+			default:
+				// Keep unknown escapes as they are, e.g. regular expressions
+				sb.WriteByte('\\')
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.pos = start
+	return nil, p.errorf("unterminated string")
+}
+
+// closes returns whether the next character after a quote is a delimiter.
+// A quote in the next line is also considered a delimiter, to report missing
+// commas in the right place.
+func (p *jsonParser) closes() bool {
+	var newline bool
+	for i := p.pos; i < len(p.text); i++ {
+		switch p.text[i] {
+		case '\n':
+			newline = true
+		case ' ', '\t', '\r':
+		case ',', ':', '}', ']':
+			return true
+		case '"', '\'':
+			return newline
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unicode parses the hex digits of a \u escape sequence including surrogate
+// pairs.
+func (p *jsonParser) unicode() (rune, bool) {
+	hex := func() (rune, bool) {
+		if p.pos+4 > len(p.text) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(p.text[p.pos:p.pos+4], 16, 32)
+		if err != nil {
+			return 0, false
+		}
+		p.pos += 4
+		return rune(n), true
+	}
+	r, ok := hex()
+	if !ok {
+		return 0, false
+	}
+	if r >= 0xd800 && r < 0xdc00 && strings.HasPrefix(p.text[p.pos:], `\u`) {
+		p.pos += 2
+		low, ok := hex()
+		if !ok {
+			p.pos -= 2
+			return utf8.RuneError, true
+		}
+		return (r-0xd800)<<10 + (low - 0xdc00) + 0x10000, true
+	}
+	return r, true
+}
+
+func (p *jsonParser) number() (any, *ParseError) {
+	start := p.pos
+	for p.pos < len(p.text) && strings.IndexByte("+-0123456789.eE", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+	n, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	return n, nil
+}
+
+// word parses an identifier, used for literals and unquoted keys.
+func (p *jsonParser) word() string {
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if c == '_' || c == '-' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.text[start:p.pos]
+}
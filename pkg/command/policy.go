@@ -0,0 +1,197 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy allows or denies commands based on a list of rules.
+//
+// Rules are evaluated in order and the first rule matching the command and its
+// argument patterns decides. A deny rule rejects the command. An allow rule
+// accepts it as long as its domain and size constraints are met.
+// If no rule matches, the default action is applied.
+//
+// Example:
+//
+//	default: allow
+//	rules:
+//	  - command: bash
+//	    action: deny
+//	    args:
+//	      command: 'rm -rf|curl .*\| *sh'
+//	    reason: destructive commands are not allowed
+//	  - command: web
+//	    action: allow
+//	    domains: [wikipedia.org, golang.org]
+//	  - command: write
+//	    action: allow
+//	    max-size: 65536
+type Policy struct {
+	Default string       `yaml:"default"`
+	Rules   []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule is a rule of a policy.
+type PolicyRule struct {
+	// Command name, "*" matches any command
+	Command string `yaml:"command"`
+	// Action is "allow" or "deny"
+	Action string `yaml:"action"`
+	// Args are regular expressions that the arguments must match, rules with
+	// patterns for missing arguments don't match
+	Args map[string]string `yaml:"args"`
+	// Domains allowed for the url argument, subdomains are included
+	Domains []string `yaml:"domains"`
+	// MaxSize is the maximum size in bytes of the content arguments
+	MaxSize int `yaml:"max-size"`
+	// Reason is reported to the AI when the command is denied
+	Reason string `yaml:"reason"`
+
+	args map[string]*regexp.Regexp
+}
+
+// contentArgs are the arguments measured by the max size of the rules.
+var contentArgs = []string{"contents", "replace", "diff", "code", "json", "form"}
+
+// PolicyError is returned when a command is denied by the policy.
+type PolicyError struct {
+	Command string
+	Rule    int
+	Reason  string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("command %s denied by policy: %s", e.Command, e.Reason)
+}
+
+// LoadPolicy loads a policy from a YAML file.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("command: couldn't read policy: %w", err)
+	}
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("command: couldn't parse policy %s: %w", file, err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, fmt.Errorf("command: invalid policy %s: %w", file, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) compile() error {
+	switch p.Default {
+	case "":
+		p.Default = "allow"
+	case "allow", "deny":
+	default:
+		return fmt.Errorf("invalid default action %q", p.Default)
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Command == "" {
+			return fmt.Errorf("rule %d: missing command", i+1)
+		}
+		if r.Action != "allow" && r.Action != "deny" {
+			return fmt.Errorf("rule %d: invalid action %q", i+1, r.Action)
+		}
+		r.args = map[string]*regexp.Regexp{}
+		for name, expr := range r.Args {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("rule %d: invalid pattern for %s: %w", i+1, name, err)
+			}
+			r.args[name] = re
+		}
+	}
+	return nil
+}
+
+// Check returns a *PolicyError if the command isn't allowed.
+func (p *Policy) Check(name string, args Args) error {
+	for i, r := range p.Rules {
+		if !r.matches(name, args) {
+			continue
+		}
+		deny := func(reason string) error {
+			if r.Reason != "" {
+				reason = r.Reason
+			}
+			return &PolicyError{Command: name, Rule: i + 1, Reason: reason}
+		}
+		if r.Action == "deny" {
+			return deny("command not allowed")
+		}
+		if len(r.Domains) > 0 {
+			host := urlHost(args.String("url"))
+			if !matchDomain(r.Domains, host) {
+				return deny(fmt.Sprintf("domain %q not allowed, allowed domains: %s", host, strings.Join(r.Domains, ", ")))
+			}
+		}
+		if r.MaxSize > 0 {
+			size := 0
+			for _, name := range contentArgs {
+				size += len(args.String(name))
+			}
+			if size > r.MaxSize {
+				return deny(fmt.Sprintf("size %d exceeds the limit of %d bytes", size, r.MaxSize))
+			}
+		}
+		return nil
+	}
+	if p.Default == "deny" {
+		return &PolicyError{Command: name, Reason: "command not allowed"}
+	}
+	return nil
+}
+
+func (r *PolicyRule) matches(name string, args Args) bool {
+	if r.Command != "*" && r.Command != name {
+		return false
+	}
+	for arg, re := range r.args {
+		v, ok := args[arg]
+		if !ok || v == nil {
+			return false
+		}
+		if !re.MatchString(fmt.Sprintf("%v", v)) {
+			return false
+		}
+	}
+	return true
+}
+
+func urlHost(u string) string {
+	if !strings.Contains(u, "://") {
+		u = "https://" + u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// matchDomain returns whether the host is any of the domains or a subdomain.
+func matchDomain(domains []string, host string) bool {
+	if host == "" {
+		return false
+	}
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxSearchFileSize is the maximum size of the files to be searched.
+const maxSearchFileSize = 2 * 1024 * 1024
+
+var errMaxMatches = errors.New("max matches reached")
+
+// SearchCommand searches text in the files of the output directory
+type SearchCommand struct {
+	ws *Workspace
+}
+
+func (c *SearchCommand) Name() string {
+	return "search"
+}
+
+func (c *SearchCommand) Description() string {
+	return "Search text in files"
+}
+
+func (c *SearchCommand) Args() []Arg {
+	return []Arg{
+		{Name: "pattern", Type: TypeString, Required: true, Description: "text to search"},
+		{Name: "directory", Type: TypeString, Default: ".", Description: "directory to search in"},
+		{Name: "regex", Type: TypeBool, Default: false, Description: "whether the pattern is a regular expression"},
+		{Name: "include", Type: TypeString, Description: "comma separated globs of the files to search, e.g. *.go"},
+		{Name: "exclude", Type: TypeString, Description: "comma separated globs of the files to skip"},
+		{Name: "max", Type: TypeInt, Default: 50, Description: "max number of matches"},
+		{Name: "context", Type: TypeInt, Default: 0, Description: "number of lines shown around each match"},
+	}
+}
+
+// SearchResult is the result of a search command.
+type SearchResult struct {
+	Matches   []string `json:"matches"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+func (c *SearchCommand) Run(ctx context.Context, args Args) any {
+	dir, err := c.ws.ReadPath(args.String("directory"))
+	if err != nil {
+		return logErr(err)
+	}
+	pattern := args.String("pattern")
+	if !args.Bool("regex") {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return logErr(fmt.Errorf("invalid regex: %w", err))
+	}
+	include := splitGlobs(args.String("include"))
+	exclude := splitGlobs(args.String("exclude"))
+	limit := args.Int("max")
+	if limit <= 0 {
+		limit = 50
+	}
+	around := args.Int("context")
+
+	result := &SearchResult{Matches: []string{}}
+	hits := 0
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel := c.ws.Rel(path)
+		if info.IsDir() {
+			if path != dir && (info.Name() == ".git" || matchGlobs(exclude, rel, info.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() > maxSearchFileSize {
+			return nil
+		}
+		if len(include) > 0 && !matchGlobs(include, rel, info.Name()) {
+			return nil
+		}
+		if matchGlobs(exclude, rel, info.Name()) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		// Skip binary files
+		if bytes.IndexByte(data[:minInt(len(data), 8000)], 0) >= 0 {
+			return nil
+		}
+
+		var lines []string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), maxSearchFileSize)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		last := -1
+		for i, line := range lines {
+			if !re.MatchString(line) {
+				continue
+			}
+			if hits >= limit {
+				result.Truncated = true
+				return errMaxMatches
+			}
+			hits++
+			// Add context lines, matching lines use ":" and context lines "-"
+			from, to := i-around, i+around
+			if from <= last {
+				from = last + 1
+			}
+			if to >= len(lines) {
+				to = len(lines) - 1
+			}
+			for j := from; j <= to; j++ {
+				sep := "-"
+				if re.MatchString(lines[j]) {
+					sep = ":"
+				}
+				if j > i && sep == ":" {
+					break
+				}
+				result.Matches = append(result.Matches, fmt.Sprintf("%s%s%d%s %s", rel, sep, j+1, sep, lines[j]))
+				last = j
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errMaxMatches {
+		return logErr(fmt.Errorf("couldn't search files: %w", err))
+	}
+	return result
+}
+
+func splitGlobs(s string) []string {
+	var globs []string
+	for _, g := range strings.Split(s, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			globs = append(globs, g)
+		}
+	}
+	return globs
+}
+
+// matchGlobs returns whether the relative path or the base name of a file
+// match any of the globs.
+func matchGlobs(globs []string, rel, name string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
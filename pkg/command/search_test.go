@@ -0,0 +1,142 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	secret := t.TempDir()
+	files := map[string]string{
+		"main.go":           "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		"util/util.go":      "package util\n\n// hello world\nfunc Hello() {}\n",
+		"util/util_test.go": "package util\n\n// hello test\n",
+		"README.md":         "# hello\nworld\n",
+		"data.bin":          "hello\x00world\n",
+		".git/config":       "hello\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(secret, "secret.txt"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	cmd := &SearchCommand{ws: NewWorkspace(dir, nil)}
+
+	tests := []struct {
+		name      string
+		args      map[string]any
+		want      []string
+		truncated bool
+		wantErr   string
+	}{
+		{
+			name: "all",
+			args: map[string]any{"pattern": "hello"},
+			want: []string{
+				"README.md:1: # hello",
+				"main.go:4: \tprintln(\"hello\")",
+				"util/util.go:3: // hello world",
+				"util/util_test.go:3: // hello test",
+			},
+		},
+		{
+			name: "include",
+			args: map[string]any{"pattern": "hello", "include": "*.go"},
+			want: []string{
+				"main.go:4: \tprintln(\"hello\")",
+				"util/util.go:3: // hello world",
+				"util/util_test.go:3: // hello test",
+			},
+		},
+		{
+			name: "include-exclude",
+			args: map[string]any{"pattern": "hello", "include": "*.go, *.md", "exclude": "*_test.go,main.go"},
+			want: []string{
+				"README.md:1: # hello",
+				"util/util.go:3: // hello world",
+			},
+		},
+		{
+			name: "exclude-directory",
+			args: map[string]any{"pattern": "hello", "exclude": "util"},
+			want: []string{
+				"README.md:1: # hello",
+				"main.go:4: \tprintln(\"hello\")",
+			},
+		},
+		{
+			name: "regex-context",
+			args: map[string]any{"pattern": "^func", "regex": true, "directory": "util", "context": 1},
+			want: []string{
+				"util/util.go-3- // hello world",
+				"util/util.go:4: func Hello() {}",
+			},
+		},
+		{
+			name: "context-merged",
+			args: map[string]any{"pattern": "hello|world", "regex": true, "include": "*.md", "context": 2},
+			want: []string{
+				"README.md:1: # hello",
+				"README.md:2: world",
+			},
+		},
+		{
+			name:      "max",
+			args:      map[string]any{"pattern": "hello", "max": 2},
+			want:      []string{"README.md:1: # hello", "main.go:4: \tprintln(\"hello\")"},
+			truncated: true,
+		},
+		{
+			name:    "outside",
+			args:    map[string]any{"pattern": "hello", "directory": ".."},
+			wantErr: "outside the workspace",
+		},
+		{
+			name:    "symlink",
+			args:    map[string]any{"pattern": "hello", "directory": "link"},
+			wantErr: "links outside the workspace",
+		},
+		{
+			name:    "invalid-regex",
+			args:    map[string]any{"pattern": "(", "regex": true},
+			wantErr: "invalid regex",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Validate(cmd, []any{tt.args})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := cmd.Run(context.Background(), args)
+			if tt.wantErr != "" {
+				if msg := errorOf(got); !strings.Contains(msg, tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, got)
+				}
+				return
+			}
+			res, ok := got.(*SearchResult)
+			if !ok {
+				t.Fatalf("unexpected result %v", got)
+			}
+			if !reflect.DeepEqual(res.Matches, tt.want) || res.Truncated != tt.truncated {
+				t.Errorf("got %q (truncated %v), want %q (truncated %v)", res.Matches, res.Truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace resolves the paths used by commands, ensuring they don't escape
+// the output directory.
+// Read-only mounts expose external directories inside the workspace.
+type Workspace struct {
+	root   string
+	mounts map[string]string
+}
+
+// NewWorkspace creates a workspace in the given root directory.
+// Mounts are in the format "name=path" or "path", in the latter the base name
+// of the path is used as the name of the mount.
+func NewWorkspace(root string, mounts []string) *Workspace {
+	w := &Workspace{
+		root:   absPath(root),
+		mounts: map[string]string{},
+	}
+	for _, m := range mounts {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		name, path := filepath.Base(m), m
+		if i := strings.Index(m, "="); i > 0 {
+			name, path = m[:i], m[i+1:]
+		}
+		w.mounts[name] = absPath(path)
+	}
+	return w
+}
+
+// Root returns the root directory of the workspace.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Mounts returns the names of the read-only mounts.
+func (w *Workspace) Mounts() []string {
+	var names []string
+	for name := range w.mounts {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Path returns the absolute path of the given workspace path to be written.
+// It fails if the path is outside the workspace or inside a read-only mount.
+func (w *Workspace) Path(path string) (string, error) {
+	if mount, _ := w.mount(path); mount != "" {
+		return "", fmt.Errorf("path %q is inside read-only mount %q", path, mount)
+	}
+	return w.resolve(w.root, path, path)
+}
+
+// ReadPath returns the absolute path of the given workspace path to be read.
+// It fails if the path is outside the workspace and its read-only mounts.
+func (w *Workspace) ReadPath(path string) (string, error) {
+	if mount, rest := w.mount(path); mount != "" {
+		return w.resolve(w.mounts[mount], rest, path)
+	}
+	return w.resolve(w.root, path, path)
+}
+
+// Rel returns the workspace path of the given absolute path.
+func (w *Workspace) Rel(path string) string {
+	for name, dir := range w.mounts {
+		if rel, ok := within(dir, path); ok {
+			return filepath.ToSlash(filepath.Join(name, rel))
+		}
+	}
+	if rel, ok := within(w.root, path); ok {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// dirs returns the root directory and the directories of the mounts.
+func (w *Workspace) dirs() []string {
+	dirs := []string{w.root}
+	for _, dir := range w.mounts {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// contains returns whether the absolute path, after following symlinks, is
+// inside the workspace or its mounts.
+func (w *Workspace) contains(path string) bool {
+	realPath, err := evalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range w.dirs() {
+		realDir, err := evalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if _, ok := within(realDir, realPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mount returns the mount name and the remaining path if the path is inside
+// a mount.
+func (w *Workspace) mount(path string) (string, string) {
+	clean := filepath.ToSlash(filepath.Clean(path))
+	clean = strings.TrimPrefix(clean, "/")
+	name, rest := clean, "."
+	if i := strings.Index(clean, "/"); i >= 0 {
+		name, rest = clean[:i], clean[i+1:]
+	}
+	if _, ok := w.mounts[name]; !ok {
+		return "", ""
+	}
+	// The mount shadows any workspace path with the same name
+	return name, rest
+}
+
+// resolve joins the path to the base directory and checks that the result,
+// after following symlinks, is still inside the base directory.
+func (w *Workspace) resolve(base, path, orig string) (string, error) {
+	if filepath.IsAbs(path) {
+		if _, ok := within(base, filepath.Clean(path)); !ok {
+			return "", fmt.Errorf("path %q is outside the workspace, use relative paths", orig)
+		}
+	} else {
+		path = filepath.Join(base, path)
+	}
+	if _, ok := within(base, path); !ok {
+		return "", fmt.Errorf("path %q is outside the workspace", orig)
+	}
+
+	// Follow symlinks
+	realBase, err := evalSymlinks(base)
+	if err != nil {
+		return "", fmt.Errorf("couldn't resolve workspace: %w", err)
+	}
+	realPath, err := evalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't resolve path %q: %w", orig, err)
+	}
+	if _, ok := within(realBase, realPath); !ok {
+		return "", fmt.Errorf("path %q links outside the workspace", orig)
+	}
+	return path, nil
+}
+
+// within returns the relative path and whether the path is inside the base
+// directory.
+func within(base, path string) (string, bool) {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return "", false
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// evalSymlinks is like filepath.EvalSymlinks but it also works with paths
+// that don't exist yet and with dangling symlinks.
+func evalSymlinks(path string) (string, error) {
+	for i := 0; i < 255; i++ {
+		// Search the longest existing prefix of the path
+		cur, rest := path, ""
+		for {
+			_, err := os.Lstat(cur)
+			if err == nil {
+				break
+			}
+			if !os.IsNotExist(err) {
+				return "", err
+			}
+			parent := filepath.Dir(cur)
+			if parent == cur {
+				return path, nil
+			}
+			rest = filepath.Join(filepath.Base(cur), rest)
+			cur = parent
+		}
+		real, err := filepath.EvalSymlinks(cur)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		// The existing prefix is a dangling symlink, replace it with its target
+		target, err := os.Readlink(cur)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(cur), target)
+		}
+		path = filepath.Join(target, rest)
+	}
+	return "", fmt.Errorf("too many levels of symbolic links")
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
@@ -0,0 +1,66 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "output")
+	ref := filepath.Join(dir, "ref")
+	for _, d := range []string{root, ref, filepath.Join(dir, "secret")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	ws := NewWorkspace(root, []string{"docs=" + ref})
+
+	tests := []struct {
+		name    string
+		path    string
+		read    bool
+		want    string
+		wantErr bool
+	}{
+		{name: "file", path: "a/b.txt", want: filepath.Join(root, "a", "b.txt")},
+		{name: "root", path: ".", want: root},
+		{name: "parent", path: "../secret/id_rsa", wantErr: true},
+		{name: "nested-parent", path: "a/../../secret", wantErr: true},
+		{name: "absolute", path: "/etc/passwd", wantErr: true},
+		{name: "absolute-inside", path: filepath.Join(root, "c.txt"), want: filepath.Join(root, "c.txt")},
+		{name: "symlink", path: "link/id_rsa", wantErr: true},
+		{name: "dangling-symlink", path: "dangling", wantErr: true},
+		{name: "mount-read", path: "docs/readme.md", read: true, want: filepath.Join(ref, "readme.md")},
+		{name: "mount-write", path: "docs/readme.md", wantErr: true},
+		{name: "mount-parent", path: "docs/../../secret", read: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolve := ws.Path
+			if tt.read {
+				resolve = ws.ReadPath
+			}
+			got, err := resolve(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -49,6 +49,10 @@ func (m *fixedMemory) Sum() ([]memory.Message, error) {
 				return nil, fmt.Errorf("openai: prompt too long (%d tokens)", tokens)
 			}
 			rest = rest[1:]
+			// Tool results can't be sent without the call that requested them
+			for len(rest) > 1 && rest[0].Role == "tool" {
+				rest = rest[1:]
+			}
 		}
 	}
 	return append(first, rest...), nil
@@ -58,8 +62,22 @@ func Tokens(messages []memory.Message) (int, error) {
 	text := ""
 	for _, message := range messages {
 		text += message.Content + "\n"
+		for _, c := range message.ToolCalls {
+			text += c.Name + " " + c.Arguments + "\n"
+		}
+	}
+	tokens, err := Count(text)
+	if err != nil {
+		return 0, err
 	}
 
+	// Add 8 tokens extra per message
+	tokens = tokens + (len(messages) * 8)
+	return tokens, nil
+}
+
+// Count returns the number of tokens of the given text.
+func Count(text string) (int, error) {
 	enc, err := tokenizer.Get(tokenizer.Cl100kBase)
 	if err != nil {
 		return 0, fmt.Errorf("openai: couldn't get tokenizer: %w", err)
@@ -67,9 +85,5 @@ func Tokens(messages []memory.Message) (int, error) {
 
 	// Encode to obtain the list of tokens
 	ids, _, _ := enc.Encode(text)
-	tokens := len(ids)
-
-	// Add 8 tokens extra per message
-	tokens = tokens + (len(messages) * 8)
-	return tokens, nil
+	return len(ids), nil
 }
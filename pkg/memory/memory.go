@@ -3,6 +3,18 @@ package memory
 type Message struct {
 	Role    string
 	Content string
+
+	// Tools called by the assistant
+	ToolCalls []ToolCall
+	// ID of the tool call answered by a tool message
+	ToolCallID string
+}
+
+// ToolCall is a call to a tool requested by the assistant.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
 }
 
 type Memory interface {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/PullRequestInc/go-gpt3"
@@ -17,6 +18,9 @@ type Client struct {
 	gpt3.Client
 	rateLimit ratelimit.Lock
 	maxTokens int
+	key       string
+	baseURL   string
+	http      *http.Client
 }
 
 // New returns a new Client.
@@ -32,6 +36,9 @@ func New(key string, wait time.Duration, maxTokens int) *Client {
 		Client:    client,
 		rateLimit: rateLimit,
 		maxTokens: maxTokens,
+		key:       key,
+		baseURL:   "https://api.openai.com/v1",
+		http:      &http.Client{Timeout: 5 * time.Minute},
 	}
 }
 
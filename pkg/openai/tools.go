@@ -0,0 +1,233 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/igolaizola/igogpt/pkg/memory"
+)
+
+// Tool is a function that the model can call.
+type Tool struct {
+	Name        string
+	Description string
+	// JSON schema of the arguments
+	Parameters any
+}
+
+// ToolChat is a chat session where the model can call tools.
+type ToolChat struct {
+	client *Client
+	model  string
+	tools  []Tool
+	memory memory.Memory
+}
+
+// ToolChat creates a new chat session with the given tools available.
+func (c *Client) ToolChat(model string, tools []Tool, mem memory.Memory) *ToolChat {
+	return &ToolChat{
+		client: c,
+		model:  model,
+		tools:  tools,
+		memory: mem,
+	}
+}
+
+// Send adds the messages to the chat and returns the response of the
+// assistant, which may contain tool calls.
+// The results of the tool calls must be sent back using messages with the
+// "tool" role and the ID of each call.
+func (c *ToolChat) Send(ctx context.Context, msgs ...memory.Message) (*memory.Message, error) {
+	for _, m := range msgs {
+		if err := c.memory.Add(m); err != nil {
+			return nil, fmt.Errorf("openai: couldn't add message to memory: %w", err)
+		}
+	}
+	sum, err := c.memory.Sum()
+	if err != nil {
+		return nil, fmt.Errorf("openai: couldn't sum memory: %w", err)
+	}
+
+	// Rate limit requests
+	unlock := c.client.rateLimit.Lock(ctx)
+	defer unlock()
+
+	req := &toolRequest{
+		Model:     c.model,
+		MaxTokens: c.client.maxTokens,
+	}
+	for _, m := range sum {
+		req.Messages = append(req.Messages, toToolMessage(m))
+	}
+	for _, t := range c.tools {
+		req.Tools = append(req.Tools, toolDef{
+			Type: "function",
+			Function: toolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+
+	var resp *toolResponse
+	for {
+		resp, err = c.client.doTools(ctx, req)
+		if errors.Is(err, errTooManyRequests) {
+			// Rate limit error, wait and try again
+			log.Println("openai: too many requests, waiting for 30 seconds...")
+			select {
+			case <-time.After(30 * time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("openai: no choices")
+	}
+	log.Printf("openai: request tokens %d", resp.Usage.TotalTokens)
+
+	msg := fromToolMessage(resp.Choices[0].Message)
+	if err := c.memory.Add(msg); err != nil {
+		return nil, fmt.Errorf("openai: couldn't add message to memory: %w", err)
+	}
+	return &msg, nil
+}
+
+var errTooManyRequests = errors.New("openai: too many requests")
+
+// doTools sends a chat completion request with tools.
+// The go-gpt3 client doesn't support tools, so the request is done directly.
+func (c *Client) doTools(ctx context.Context, req *toolRequest) (*toolResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai: couldn't marshal request: %w", err)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("openai: couldn't create request: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+c.key)
+	resp, err := c.http.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("openai: couldn't generate completion: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: couldn't read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, errTooManyRequests
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &apiErr); err == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("openai: couldn't generate completion: %d %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("openai: couldn't generate completion: %d %s", resp.StatusCode, string(data))
+	}
+	var out toolResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("openai: couldn't unmarshal response: %w", err)
+	}
+	return &out, nil
+}
+
+type toolRequest struct {
+	Model     string        `json:"model"`
+	Messages  []toolMessage `json:"messages"`
+	Tools     []toolDef     `json:"tools,omitempty"`
+	MaxTokens int           `json:"max_tokens,omitempty"`
+}
+
+type toolDef struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type toolMessage struct {
+	Role       string     `json:"role"`
+	Content    *string    `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type toolResponse struct {
+	Choices []struct {
+		Message toolMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func toToolMessage(m memory.Message) toolMessage {
+	out := toolMessage{
+		Role:       m.Role,
+		ToolCallID: m.ToolCallID,
+	}
+	// Content is null for assistant messages with only tool calls
+	if m.Content != "" || len(m.ToolCalls) == 0 {
+		content := m.Content
+		out.Content = &content
+	}
+	for _, c := range m.ToolCalls {
+		tc := toolCall{ID: c.ID, Type: "function"}
+		tc.Function.Name = c.Name
+		tc.Function.Arguments = c.Arguments
+		out.ToolCalls = append(out.ToolCalls, tc)
+	}
+	return out
+}
+
+func fromToolMessage(m toolMessage) memory.Message {
+	out := memory.Message{
+		Role:       m.Role,
+		ToolCallID: m.ToolCallID,
+	}
+	if m.Content != nil {
+		out.Content = *m.Content
+	}
+	for _, c := range m.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, memory.ToolCall{
+			ID:        c.ID,
+			Name:      c.Function.Name,
+			Arguments: c.Function.Arguments,
+		})
+	}
+	return out
+}
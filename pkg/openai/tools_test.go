@@ -0,0 +1,74 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/igogpt/pkg/memory"
+	"github.com/igolaizola/igogpt/pkg/memory/fixed"
+)
+
+func TestToolChat(t *testing.T) {
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, req)
+		if len(requests) == 1 {
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"read","arguments":"{\"filename\":\"a.txt\"}"}}]}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"done"}}]}`))
+	}))
+	defer srv.Close()
+
+	client := New("key", time.Millisecond, 0)
+	client.baseURL = srv.URL
+	tools := []Tool{{Name: "read", Description: "Read file", Parameters: map[string]any{"type": "object"}}}
+	chat := client.ToolChat("gpt-4", tools, fixed.NewFixedMemory(1, 0))
+
+	ctx := context.Background()
+	msg, err := chat.Send(ctx, memory.Message{Role: "system", Content: "prompt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []memory.ToolCall{{ID: "call_1", Name: "read", Arguments: `{"filename":"a.txt"}`}}
+	if !reflect.DeepEqual(msg.ToolCalls, want) {
+		t.Errorf("tool calls = %v, want %v", msg.ToolCalls, want)
+	}
+
+	msg, err = chat.Send(ctx, memory.Message{Role: "tool", Content: "hello", ToolCallID: "call_1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "done" {
+		t.Errorf("content = %q, want %q", msg.Content, "done")
+	}
+
+	// The second request must include the tool call and its result
+	msgs := requests[1]["messages"].([]any)
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %v", msgs)
+	}
+	call := msgs[1].(map[string]any)
+	if call["content"] != nil || call["tool_calls"] == nil {
+		t.Errorf("unexpected assistant message %v", call)
+	}
+	result := msgs[2].(map[string]any)
+	if result["role"] != "tool" || result["tool_call_id"] != "call_1" || result["content"] != "hello" {
+		t.Errorf("unexpected tool message %v", result)
+	}
+	if tools := requests[0]["tools"].([]any); len(tools) != 1 {
+		t.Errorf("expected 1 tool, got %v", tools)
+	}
+}
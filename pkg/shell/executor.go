@@ -0,0 +1,80 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Executor executes command requests.
+type Executor interface {
+	Run(ctx context.Context, req *Request) (*Result, error)
+}
+
+// Direct executes commands directly in the host.
+type Direct struct{}
+
+// Run executes the command in the host.
+func (Direct) Run(ctx context.Context, req *Request) (*Result, error) {
+	return Run(ctx, req)
+}
+
+// Wrapper executes commands through a prefix command, e.g. a sandbox tool.
+// The "{dir}" placeholder in the prefix is replaced with the absolute path of
+// the working directory.
+type Wrapper struct {
+	Prefix []string
+}
+
+// Run executes the command prefixed with the wrapper command.
+func (w *Wrapper) Run(ctx context.Context, req *Request) (*Result, error) {
+	dir, err := filepath.Abs(req.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("shell: couldn't get absolute path: %w", err)
+	}
+	var cmd []string
+	for _, p := range w.Prefix {
+		cmd = append(cmd, strings.ReplaceAll(p, "{dir}", dir))
+	}
+	wrapped := *req
+	wrapped.Command = append(cmd, req.Command...)
+	return Run(ctx, &wrapped)
+}
+
+// Prefixes of the supported sandbox backends.
+// All of them but unshare give write access only to the working directory.
+// The unshare backend only isolates the processes, the user runs as a fake
+// root without privileges outside the namespace and the whole filesystem
+// stays writable with the permissions of the user.
+var prefixes = map[string]string{
+	"bwrap":    "bwrap --ro-bind / / --dev /dev --proc /proc --tmpfs /tmp --bind {dir} {dir} --chdir {dir} --unshare-all --share-net --die-with-parent --",
+	"firejail": "firejail --quiet --noprofile --read-only=/ --read-write={dir} --private-tmp",
+	"nsjail":   "nsjail --mode o --quiet --time_limit 0 --keep_env --disable_clone_newnet --chroot / --bindmount {dir} --cwd {dir} --",
+	"unshare":  "unshare --map-root-user --fork --pid --mount-proc --",
+}
+
+// NewExecutor returns the executor of the given backend.
+// Available backends are "direct", "wrapper" and the sandbox tools "bwrap",
+// "firejail", "nsjail" and "unshare".
+// The prefix overrides the default prefix of a sandbox tool and it is required
+// by the "wrapper" backend. Its arguments are separated by spaces.
+func NewExecutor(backend, prefix string) (Executor, error) {
+	switch backend {
+	case "", "direct":
+		return Direct{}, nil
+	case "wrapper":
+		if prefix == "" {
+			return nil, fmt.Errorf("shell: prefix is required for wrapper backend")
+		}
+	default:
+		p, ok := prefixes[backend]
+		if !ok {
+			return nil, fmt.Errorf("shell: unknown backend %q", backend)
+		}
+		if prefix == "" {
+			prefix = p
+		}
+	}
+	return &Wrapper{Prefix: strings.Fields(prefix)}, nil
+}
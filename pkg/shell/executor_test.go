@@ -0,0 +1,104 @@
+//go:build !windows
+
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrapper(t *testing.T) {
+	dir := t.TempDir()
+
+	// Fake sandbox that records its arguments and runs the wrapped command
+	script := filepath.Join(dir, "sandbox.sh")
+	record := filepath.Join(dir, "args.txt")
+	content := "#!/bin/sh\necho \"$@\" > " + record + "\nshift 2\nexec \"$@\"\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	exec, err := NewExecutor("wrapper", script+" --bind {dir}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := exec.Run(context.Background(), &Request{
+		Command: []string{"sh", "-c", "pwd"},
+		Dir:     dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(res.Stdout) != dir || res.ExitCode != 0 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	args, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--bind " + dir + " sh -c pwd\n"; string(args) != want {
+		t.Errorf("wrapper args = %q, want %q", args, want)
+	}
+}
+
+func TestNewExecutor(t *testing.T) {
+	if _, err := NewExecutor("wrapper", ""); err == nil {
+		t.Error("expected error for wrapper without prefix")
+	}
+	if _, err := NewExecutor("unknown", ""); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+	exec, err := NewExecutor("bwrap", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, ok := exec.(*Wrapper); !ok || w.Prefix[0] != "bwrap" {
+		t.Errorf("unexpected executor: %#v", exec)
+	}
+}
+
+func TestSandboxWrite(t *testing.T) {
+	// The unshare backend doesn't restrict writes
+	for _, backend := range []string{"bwrap", "firejail", "nsjail"} {
+		t.Run(backend, func(t *testing.T) {
+			if _, err := exec.LookPath(backend); err != nil {
+				t.Skipf("%s not found", backend)
+			}
+			dir := t.TempDir()
+			outside := t.TempDir()
+			e, err := NewExecutor(backend, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			run := func(file string) *Result {
+				t.Helper()
+				res, err := e.Run(context.Background(), &Request{
+					Command: []string{"sh", "-c", "echo x > " + file},
+					Dir:     dir,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return res
+			}
+
+			// Skip if the sandbox can't run in this environment
+			inside := filepath.Join(dir, "inside.txt")
+			if res := run(inside); res.ExitCode != 0 {
+				t.Skipf("%s not available: %s", backend, res.Stderr)
+			}
+			if _, err := os.Stat(inside); err != nil {
+				t.Errorf("write inside the working directory failed: %v", err)
+			}
+			file := filepath.Join(outside, "outside.txt")
+			run(file)
+			if _, err := os.Stat(file); !os.IsNotExist(err) {
+				t.Errorf("write outside the working directory succeeded")
+			}
+		})
+	}
+}
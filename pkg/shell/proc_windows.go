@@ -0,0 +1,16 @@
+//go:build windows
+
+package shell
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of the command, process groups aren't
+// supported on windows.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,198 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// killWait is the maximum time to wait for a killed command to finish.
+// A process that left the process group can keep the output open, so the
+// wait could block forever.
+var killWait = 5 * time.Second
+
+// DefaultEnv is the list of environment variables passed to commands when no
+// allowlist is provided.
+var DefaultEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_*", "TERM", "TMPDIR", "TZ", "SHELL"}
+
+// Request represents a command to be executed.
+type Request struct {
+	// Command is the program to execute followed by its arguments
+	Command []string
+	// Dir is the working directory of the command
+	Dir string
+	// Env is the environment of the command in the format "KEY=value", if nil
+	// the environment of the current process is used
+	Env []string
+	// Timeout is the maximum duration of the command, zero means no timeout
+	Timeout time.Duration
+	// MaxOutput is the maximum number of bytes kept for each output stream,
+	// zero means no limit
+	MaxOutput int
+}
+
+// Result represents the result of an executed command.
+type Result struct {
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	ExitCode  int    `json:"exit_code"`
+	Duration  string `json:"duration"`
+	Truncated bool   `json:"truncated"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Run executes the command and waits for it to finish.
+// The whole process group is killed if the context is canceled or the timeout
+// is reached.
+// An error is only returned if the command couldn't be started, failures of
+// the command itself are reported in the result.
+func Run(ctx context.Context, req *Request) (*Result, error) {
+	if len(req.Command) == 0 {
+		return nil, errors.New("shell: empty command")
+	}
+	cmd := exec.Command(req.Command[0], req.Command[1:]...)
+	cmd.Dir = req.Dir
+	cmd.Env = req.Env
+	stdout := &capWriter{max: req.MaxOutput}
+	stderr := &capWriter{max: req.MaxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("shell: couldn't start command: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if req.Timeout > 0 {
+		timer := time.NewTimer(req.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	var killed string
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_ = killProcessGroup(cmd)
+		err = waitKilled(done)
+		killed = "command canceled"
+	case <-timeout:
+		_ = killProcessGroup(cmd)
+		err = waitKilled(done)
+		killed = fmt.Sprintf("command killed after %s timeout", req.Timeout)
+	}
+
+	result := &Result{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+		Truncated: stdout.truncated() || stderr.truncated(),
+		Error:     killed,
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode = -1
+		if result.Error == "" {
+			result.Error = err.Error()
+		}
+	}
+	return result, nil
+}
+
+// waitKilled waits for a killed command to finish, giving up after killWait.
+func waitKilled(done <-chan error) error {
+	timer := time.NewTimer(killWait)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return errors.New("shell: output still open after the command was killed")
+	}
+}
+
+// FilterEnv returns the environment variables whose name is in the allowlist.
+// Allowlist entries ending with "*" match any variable with that prefix.
+func FilterEnv(environ []string, allow []string) []string {
+	env := []string{}
+	for _, kv := range environ {
+		name := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			name = kv[:i]
+		}
+		for _, a := range allow {
+			if a == name || (strings.HasSuffix(a, "*") && strings.HasPrefix(name, strings.TrimSuffix(a, "*"))) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// capWriter keeps the head and the tail of the written data up to a maximum
+// number of bytes.
+type capWriter struct {
+	lck   sync.Mutex
+	max   int
+	head  []byte
+	tail  []byte
+	total int
+}
+
+func (w *capWriter) Write(p []byte) (int, error) {
+	w.lck.Lock()
+	defer w.lck.Unlock()
+	n := len(p)
+	w.total += n
+	if w.max <= 0 {
+		w.head = append(w.head, p...)
+		return n, nil
+	}
+	if room := w.max/2 - len(w.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		w.head = append(w.head, p[:room]...)
+		p = p[room:]
+	}
+	w.tail = append(w.tail, p...)
+	if over := len(w.tail) - (w.max - w.max/2); over > 0 {
+		w.tail = append(w.tail[:0], w.tail[over:]...)
+	}
+	return n, nil
+}
+
+func (w *capWriter) truncated() bool {
+	w.lck.Lock()
+	defer w.lck.Unlock()
+	return w.skipped() > 0
+}
+
+func (w *capWriter) skipped() int {
+	return w.total - len(w.head) - len(w.tail)
+}
+
+func (w *capWriter) String() string {
+	w.lck.Lock()
+	defer w.lck.Unlock()
+	skipped := w.skipped()
+	if skipped == 0 {
+		return strings.ToValidUTF8(string(w.head)+string(w.tail), "")
+	}
+	return strings.ToValidUTF8(fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", w.head, skipped, w.tail), "")
+}
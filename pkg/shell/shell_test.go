@@ -0,0 +1,89 @@
+//go:build !windows
+
+package shell
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("exit-code", func(t *testing.T) {
+		res, err := Run(ctx, &Request{Command: []string{"sh", "-c", "echo out; echo err >&2; exit 3"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Stdout != "out\n" || res.Stderr != "err\n" || res.ExitCode != 3 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		res, err := Run(ctx, &Request{
+			Command: []string{"sh", "-c", "sleep 10 & sleep 10; echo done"},
+			Timeout: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("command wasn't killed")
+		}
+		if res.Error == "" || res.ExitCode == 0 || strings.Contains(res.Stdout, "done") {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("escaped", func(t *testing.T) {
+		if _, err := exec.LookPath("setsid"); err != nil {
+			t.Skip("setsid not found")
+		}
+		defer func(d time.Duration) { killWait = d }(killWait)
+		killWait = 200 * time.Millisecond
+
+		// The process in a new session isn't killed and keeps stdout open
+		start := time.Now()
+		res, err := Run(ctx, &Request{
+			Command: []string{"sh", "-c", "setsid sleep 10 & sleep 10"},
+			Timeout: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("killed command didn't return")
+		}
+		if res.Error == "" || res.ExitCode == 0 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		res, err := Run(ctx, &Request{
+			Command:   []string{"sh", "-c", "printf 'head'; head -c 1000 /dev/zero | tr '\\0' x; printf 'tail'"},
+			MaxOutput: 20,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Truncated || !strings.HasPrefix(res.Stdout, "head") || !strings.HasSuffix(res.Stdout, "tail") {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	})
+}
+
+func TestFilterEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "SECRET=1", "LC_ALL=C", "HOME=/root"}
+	got := FilterEnv(environ, []string{"PATH", "LC_*"})
+	want := []string{"PATH=/bin", "LC_ALL=C"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterEnv() = %v, want %v", got, want)
+	}
+}